@@ -1,71 +1,255 @@
 package cli
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
+
+	"uniq/dedup"
 )
 
+// Cmd is the command line of uniq: the processing options and
+// the options of the command itself
 type Cmd struct {
-	Prefix        string
-	Repeated      bool
-	Unique        bool
-	Count         bool
-	IgnoreCase    bool
-	NumFields     uint
-	SkipChars     uint
-	TakeChars     uint
-	Range         bool
-	Colorize      bool
-	FormatCounter string
-	BufferSize    uint
-    Mapper        func(string) string
-	Cutter        func(string) string
-	Fprintln      func(io.Writer, string)
+	dedup.Options
+	Repeated    bool
+	Unique      bool
+	Count       bool
+	Range       bool
+	Colorize    bool
+	PrintStats  bool
+	Cardinality bool
+	// Check prints nothing and fails with ExitDuplicates on the first duplicate
+	Check bool
+	// LineBuffered flushes the output after every line, as it is done for a terminal
+	LineBuffered bool
+	// Async writes the output on a separate goroutine
+	Async bool
+	// KeyPipeline is the spec of the dedup.Chain applied to the compared part
+	KeyPipeline string
+	// Input and Output are the files from the arguments, stdin and stdout if empty
+	Input  string
+	Output string
+
+	flags    *flag.FlagSet
+	output   io.Writer
+	pipeline dedup.Chain
 }
 
 func New() *Cmd {
 
-	return &Cmd{
-		FormatCounter: "%d %s",
-		Mapper:        func(s string) string { return s },
-		Cutter:        func(s string) string { return s },
-		Fprintln:      func(w io.Writer, s string) { fmt.Fprintln(w, s) },
-	}
+	return &Cmd{Options: dedup.DefaultOptions(), output: os.Stderr}
+}
+
+// SetOutput sets the destination of the usage and parse errors, stderr by default
+func (cmd *Cmd) SetOutput(w io.Writer) {
+	cmd.output = w
 }
 
 func (cmd *Cmd) Usage() {
-	fmt.Printf(
+	fmt.Fprintf(cmd.output,
 		("%s 1.0\n" +
 			"Author: Garry G.\n\n" +
 			"Usage of %s:\n" +
-			"uniq [-c|-d|-u|-p] [-f num_fields] [-s skip_chars] [-w check_chars] [-range] [-color] [input] [output]\n" +
+			"uniq [-c [-partial]|-d|-u|-p|-cardinality|-top n|-check] [-f num_fields] [-s skip_chars] [-w check_chars] [-key-pipeline spec] [-global [-last] [-fingerprint 64|128 [-verify]] | -bloom | -window n | -cache-size n] [-sort] [-memory-limit kb] [-jobs n] [-start-offset n] [-end-offset n] [-max-line n [-oversize policy]] [-stats] [-range] [-color] [-line-buffered] [-async] [input] [output]\n" +
 			"if input\\output not specified, then stdin and stdout are used\n" +
+			"uniq merge-counts [-partial] [-stats] [file ...]\n" +
 			"\n"),
 		filepath.Base(os.Args[0]),
 		filepath.Base(os.Args[0]),
 	)
-	flag.PrintDefaults()
+	if cmd.flags != nil {
+		cmd.flags.PrintDefaults()
+	}
+}
+
+// Parse parses the arguments without the program name. Like the flag package
+// it prints the error and the usage, and returns flag.ErrHelp for -h.
+func (cmd *Cmd) Parse(args []string) error {
+	fs := flag.NewFlagSet(filepath.Base(os.Args[0]), flag.ContinueOnError)
+	fs.SetOutput(cmd.output)
+	fs.Usage = cmd.Usage
+	cmd.flags = fs
+
+	fs.BoolVar(&cmd.Count, "c", false, "Количество вхождений каждой строки")
+	fs.BoolVar(&cmd.Repeated, "d", false, "Вывести только повторяющиеся строки")
+	fs.BoolVar(&cmd.Unique, "u", false, "Вывести только уникальные строки")
+
+	fs.BoolVar(&cmd.Check, "check", false, "Только проверить вход: код выхода 1, если есть повторяющиеся строки")
+	fs.BoolVar(&cmd.Partial, "partial", false, "Выводить -c в формате частичных счетчиков для объединения командой merge-counts")
+	fs.StringVar(&cmd.Prefix, "p", "", "Количество строк в которых есть указанная подстрока")
+	fs.BoolVar(&cmd.Cardinality, "cardinality", false, "Оценить количество различных строк (HyperLogLog)")
+	fs.UintVar(&cmd.Precision, "precision", cmd.Precision, "Точность -cardinality: 2^n регистров, от 4 до 18")
+	fs.UintVar(&cmd.ExactLimit, "exact-limit", 0, "Для -cardinality также вывести точное количество, если различных строк не больше n")
+	fs.UintVar(&cmd.Top, "top", 0, "Вывести n самых частых строк несортированного входа с погрешностью счетчика")
+	fs.UintVar(&cmd.TopCapacity, "top-capacity", 0, "Количество счетчиков для -top (по умолчанию 10*n)")
+
+	fs.BoolVar(&cmd.IgnoreCase, "i", false, "Игнорировать регистр при сравнении строк")
+	fs.UintVar(&cmd.NumFields, "f", 0, "Игнорировать n полей разделенных пробелом с начала строки")
+	fs.UintVar(&cmd.SkipChars, "s", 0, "Игнорировать n символов с начала строки")
+	fs.UintVar(&cmd.TakeChars, "w", 0, "Проверять только n символов строки")
+	fs.StringVar(&cmd.KeyPipeline, "key-pipeline", "", "Преобразования сравниваемой части строки через запятую: "+
+		strings.Join(dedup.Transformers(), ",")+" (например normalize,lower,mask:[0-9]+)")
+
+	fs.BoolVar(&cmd.Global, "global", false, "Искать повторы по всему входу, а не только среди соседних строк")
+	fs.BoolVar(&cmd.KeepLast, "last", false, "В режиме -global оставлять последнее вхождение строки вместо первого")
+	fs.UintVar(&cmd.MemoryLimit, "memory-limit", 0, "Выгружать данные во временные файлы при превышении n килобайт памяти (-global, -sort)")
+	fs.UintVar(&cmd.Window, "window", 0, "Считать строку повтором, если она встречалась среди n предыдущих строк")
+	fs.UintVar(&cmd.CacheSize, "cache-size", 0, "Считать строку повтором, если она есть в LRU-кэше из n последних различных строк")
+	fs.UintVar(&cmd.CacheBytes, "cache-bytes", 0, "Ограничить LRU-кэш n байтами памяти")
+	fs.Int64Var(&cmd.StartOffset, "start-offset", 0, "Обрабатывать строки файла, которые начинаются с байта n")
+	fs.Int64Var(&cmd.EndOffset, "end-offset", 0, "Обрабатывать строки файла, которые начинаются до байта n")
+	fs.UintVar(&cmd.Jobs, "jobs", 0, "Обрабатывать файл параллельно в n горутинах (соседние строки и -global в памяти)")
+	fs.BoolVar(&cmd.Sort, "sort", false, "Предварительно отсортировать вход по сравниваемой части строки")
+
+	fs.BoolVar(&cmd.Bloom, "bloom", false, "Режим -global с фильтром Блума: фиксированная память, возможны ложные повторы")
+	fs.UintVar(&cmd.BloomItems, "bloom-n", cmd.BloomItems, "Ожидаемое количество уникальных строк для фильтра Блума")
+	fs.Float64Var(&cmd.BloomRate, "bloom-p", cmd.BloomRate, "Допустимая вероятность ложного повтора для фильтра Блума")
+	fs.BoolVar(&cmd.BloomScale, "bloom-scale", false, "Наращивать фильтр Блума при превышении ожидаемого количества строк")
+	fs.UintVar(&cmd.Fingerprint, "fingerprint", 0, "В режиме -global хранить вместо строк их 64 или 128-битные отпечатки")
+	fs.BoolVar(&cmd.Verify, "verify", false, "Для -fingerprint перечитывать файл и сверять строки с совпавшими отпечатками")
+	fs.BoolVar(&cmd.PrintStats, "stats", false, "Вывести статистику обработки в stderr")
+
+	fs.BoolVar(&cmd.Range, "range", false, "Показать использумый диапазон символов как срез")
+	fs.BoolVar(&cmd.Colorize, "color", false, "Выделять использумый диапазон символов цветом")
+
+	fs.BoolVar(&cmd.LineBuffered, "line-buffered", false, "Сбрасывать вывод после каждой строки (по умолчанию только для терминала)")
+	fs.BoolVar(&cmd.Async, "async", false, "Записывать вывод в отдельной горутине")
+
+	fs.UintVar(&cmd.BufferSize, "buffer-size", 0, "Начальный размер буфера строки в килобайтах (>64kb), буфер растет для длинных строк")
+	fs.UintVar(&cmd.MaxLine, "max-line", 0, "Ограничить длину строки n байтами")
+	fs.Func("oversize", "Что делать со строками длиннее -max-line: error, skip, truncate, hash-only (по умолчанию error)", func(name string) (err error) {
+		cmd.Oversize, err = dedup.ParseOversize(name)
+		return
+	})
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() > 2 {
+		err := errors.New("expected at most two arguments: input and output")
+		fmt.Fprintln(cmd.output, err)
+		cmd.Usage()
+		return err
+	}
+	cmd.Input, cmd.Output = fs.Arg(0), fs.Arg(1)
+
+	if cmd.KeyPipeline != "" {
+		var err error
+		if cmd.pipeline, err = dedup.NewChain(cmd.KeyPipeline); err != nil {
+			fmt.Fprintln(cmd.output, err)
+			cmd.Usage()
+			return err
+		}
+	}
+
+	return nil
 }
 
-func (cmd *Cmd) Parse() {
-	flag.Usage = cmd.Usage
-	flag.BoolVar(&cmd.Count, "c", false, "Количество вхождений каждой строки")
-	flag.BoolVar(&cmd.Repeated, "d", false, "Вывести только повторяющиеся строки")
-	flag.BoolVar(&cmd.Unique, "u", false, "Вывести только уникальные строки")
+// Validate reports the options that can not be used together
+func (cmd *Cmd) Validate() error {
+	var groupCDU int8
+	if cmd.Count {
+		groupCDU += 1
+	}
+
+	if cmd.Prefix != "" {
+		groupCDU += 1
+	}
 
-	flag.StringVar(&cmd.Prefix, "p", "", "Количество строк в которых есть указанная подстрока")
+	if cmd.Unique {
+		groupCDU += 1
+	}
+
+	if cmd.Repeated {
+		groupCDU += 1
+	}
+
+	if cmd.Cardinality {
+		groupCDU += 1
+	}
+
+	if cmd.Top > 0 {
+		groupCDU += 1
+	}
+
+	if cmd.Check {
+		groupCDU += 1
+	}
 
-	flag.BoolVar(&cmd.IgnoreCase, "i", false, "Игнорировать регистр при сравнении строк")
-	flag.UintVar(&cmd.NumFields, "f", 0, "Игнорировать n полей разделенных пробелом с начала строки")
-	flag.UintVar(&cmd.SkipChars, "s", 0, "Игнорировать n символов с начала строки")
-	flag.UintVar(&cmd.TakeChars, "w", 0, "Проверять только n символов строки")
+	if groupCDU > 1 {
+		return errors.New("options -c, -d, -u, -p, -cardinality, -top and -check are mutually exclusive")
+	}
+
+	if cmd.StartOffset < 0 || cmd.EndOffset < 0 {
+		return errors.New("-start-offset and -end-offset must not be negative")
+	}
+
+	if cmd.Oversize != dedup.OversizeError && cmd.MaxLine == 0 {
+		return errors.New("-oversize requires -max-line")
+	}
+
+	if cmd.Check && cmd.Output != "" {
+		return errors.New("-check does not write the output file")
+	}
+
+	if cmd.Partial && !cmd.Count {
+		return errors.New("-partial requires -c")
+	}
+
+	if !(cmd.BloomRate > 0 && cmd.BloomRate < 1) {
+		return errors.New("-bloom-p must be greater than 0 and less than 1")
+	}
+
+	if cmd.Bloom && (cmd.Count || cmd.Unique || cmd.Prefix != "" || cmd.Cardinality || cmd.Top > 0) {
+		return errors.New("-bloom can be used only for deduplication, -d and -check")
+	}
+
+	if cmd.Bloom && cmd.KeepLast {
+		return errors.New("-bloom can not be used with -last")
+	}
+
+	if (cmd.Cardinality || cmd.Top > 0) && (cmd.Global || cmd.Window > 0 || cmd.CacheSize > 0 ||
+		cmd.CacheBytes > 0 || cmd.Sort || cmd.MemoryLimit > 0 || cmd.Jobs > 0) {
+		return errors.New("-cardinality and -top can not be used with -global, -window, -cache-size, -cache-bytes, -sort, -memory-limit and -jobs")
+	}
+
+	if cmd.MemoryLimit > 0 && !cmd.Global && !cmd.Sort {
+		return errors.New("-memory-limit requires -global or -sort")
+	}
+
+	var groupGWC int8
+	if cmd.Global || cmd.Bloom {
+		groupGWC += 1
+	}
+
+	if cmd.Window > 0 {
+		groupGWC += 1
+	}
+
+	if cmd.CacheSize > 0 || cmd.CacheBytes > 0 {
+		groupGWC += 1
+	}
+
+	if groupGWC > 1 {
+		return errors.New("options -global, -bloom, -window, -cache-size and -cache-bytes are mutually exclusive")
+	}
+
+	if cmd.Fingerprint > 0 && (cmd.Bloom || cmd.MemoryLimit > 0) {
+		return errors.New("-fingerprint can not be used with -bloom and -memory-limit")
+	}
+
+	if !cmd.Global && (cmd.KeepLast || cmd.Fingerprint > 0) {
+		return errors.New("-last and -fingerprint require -global")
+	}
+
+	if cmd.Verify && cmd.Fingerprint == 0 {
+		return errors.New("-verify requires -fingerprint")
+	}
 
-	flag.BoolVar(&cmd.Range, "range", false, "Показать использумый диапазон символов как срез")
-	flag.BoolVar(&cmd.Colorize, "color", false, "Выделять использумый диапазон символов цветом")
-    
-    flag.UintVar(&cmd.BufferSize, "buffer-size", 0, "Установить максимальный размер буфера для сканирования файла (>64kb)")
-	flag.Parse()
+	return nil
 }
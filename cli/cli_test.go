@@ -0,0 +1,326 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testInput = "AAA 1\naaa 2\nbbb 3\nbbb 4\nccc 5\n"
+
+func run(args ...string) (code int, stdout, stderr string) {
+	var out, errOut bytes.Buffer
+	code = Run(args, strings.NewReader(testInput), &out, &errOut)
+	return code, out.String(), errOut.String()
+}
+
+func TestRun(t *testing.T) {
+
+	testCases := []struct {
+		args     []string
+		expected string
+	}{
+		{nil, "AAA 1\naaa 2\nbbb 3\nbbb 4\nccc 5\n"},
+		{[]string{"-w", "3"}, "AAA 1\naaa 2\nbbb 3\nccc 5\n"},
+		{[]string{"-i", "-w", "3", "-u"}, "ccc 5\n"},
+		{[]string{"-i", "-w", "3", "-d"}, "aaa 1\nbbb 3\n"},
+		{[]string{"-c"}, "1 AAA 1\n1 aaa 2\n1 bbb 3\n1 bbb 4\n1 ccc 5\n"},
+		{[]string{"-w", "3", "-c"}, "1 AAA 1\n1 aaa 2\n2 bbb 3\n1 ccc 5\n"},
+		{[]string{"-global", "-last", "-i", "-w", "1"}, "aaa 2\nbbb 4\nccc 5\n"},
+		{[]string{"-p", "bb"}, "2 bb\n"},
+		{[]string{"-key-pipeline", "lower,mask"}, "AAA 1\nbbb 3\nccc 5\n"},
+		{[]string{"-f", "1", "-key-pipeline", "mask:[2-4]", "-u"}, "AAA 1\nccc 5\n"},
+		{[]string{"-range", "-s", "4"}, "[4:5] AAA 1\n[4:5] aaa 2\n[4:5] bbb 3\n[4:5] bbb 4\n[4:5] ccc 5\n"},
+		{[]string{"-async", "-w", "3", "-c"}, "1 AAA 1\n1 aaa 2\n2 bbb 3\n1 ccc 5\n"},
+		{[]string{"-line-buffered", "-i", "-w", "3", "-d"}, "aaa 1\nbbb 3\n"},
+	}
+
+	for _, tc := range testCases {
+		code, stdout, stderr := run(tc.args...)
+		if code != 0 || stderr != "" {
+			t.Errorf("%v: exit code %d, stderr %q", tc.args, code, stderr)
+		}
+		if stdout != tc.expected {
+			t.Errorf("%v: got %q, expected %q", tc.args, stdout, tc.expected)
+		}
+	}
+}
+
+func TestRunTwice(t *testing.T) {
+	for i := 0; i < 2; i++ {
+		if _, stdout, _ := run("-i", "-w", "3", "-d"); stdout != "aaa 1\nbbb 3\n" {
+			t.Errorf("run %d: got %q", i, stdout)
+		}
+	}
+}
+
+func TestRunFiles(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input.txt")
+	output := filepath.Join(dir, "output.txt")
+
+	if err := os.WriteFile(input, []byte("a\na\nb\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// the output file is created, then truncated
+	for i := 0; i < 2; i++ {
+		if code, _, stderr := run(input, output); code != 0 {
+			t.Fatalf("exit code %d: %s", code, stderr)
+		}
+
+		got, err := os.ReadFile(output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != "a\nb\n" {
+			t.Errorf("run %d: got %q in the output file", i, got)
+		}
+
+		if err := os.WriteFile(output, []byte("a longer content\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if code, stdout, stderr := run("-jobs", "4", "-c", input); code != 0 || stdout != "2 a\n1 b\n" {
+		t.Errorf("-jobs: exit code %d, got %q, %s", code, stdout, stderr)
+	}
+
+	// the duplicates of a chunk are copied in one write
+	var big strings.Builder
+	big.WriteString("first\n")
+	for i := 0; i < 20000; i++ {
+		fmt.Fprintf(&big, "line %d\nline %d\n", i, i)
+	}
+	chunks := filepath.Join(dir, "chunks.txt")
+	if err := os.WriteFile(chunks, []byte(big.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if code, _, stderr := run("-check", "-jobs", "4", chunks); code != ExitDuplicates || stderr != chunks+": duplicate line \"line 0\"\n" {
+		t.Errorf("-check -jobs: exit code %d, stderr %q", code, stderr)
+	}
+
+	// the second line starts in the first shard
+	for _, tc := range []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"-c", "-end-offset", "3", input}, "2 a\n"},
+		{[]string{"-c", "-start-offset", "3", input}, "1 b\n"},
+	} {
+		if code, stdout, stderr := run(tc.args...); code != 0 || stdout != tc.expected {
+			t.Errorf("%v: exit code %d, got %q, %s", tc.args, code, stdout, stderr)
+		}
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+
+	testCases := []struct {
+		args []string
+		code int
+	}{
+		{[]string{"-h"}, ExitOK},
+		{[]string{"-unknown"}, ExitUsage},
+		{[]string{"a", "b", "c"}, ExitUsage},
+		{[]string{"-c", "-u"}, ExitUsage},
+		{[]string{"-global", "-window", "2"}, ExitUsage},
+		{[]string{"-check", "-d"}, ExitUsage},
+		{[]string{"-key-pipeline", "lower,unknown"}, ExitUsage},
+		{[]string{"-global", "-fingerprint", "32"}, ExitUsage},
+		{[]string{"-bloom", "-bloom-p", "2"}, ExitUsage},
+		{[]string{"-bloom", "-u"}, ExitUsage},
+		{[]string{"-bloom", "-top", "1"}, ExitUsage},
+		{[]string{"-bloom", "-last"}, ExitUsage},
+		{[]string{"-last"}, ExitUsage},
+		{[]string{"-fingerprint", "64"}, ExitUsage},
+		{[]string{"-global", "-verify"}, ExitUsage},
+		{[]string{"-cardinality", "-precision", "2"}, ExitUsage},
+		{[]string{"-bloom", "-bloom-p", "-1"}, ExitUsage},
+		{[]string{"-top", "1", "-global"}, ExitUsage},
+		{[]string{"-top", "1", "-sort"}, ExitUsage},
+		{[]string{"-top", "1", "-jobs", "2"}, ExitUsage},
+		{[]string{"-cardinality", "-window", "2"}, ExitUsage},
+		{[]string{"-cardinality", "-cache-size", "2"}, ExitUsage},
+		{[]string{"-cardinality", "-global", "-memory-limit", "4"}, ExitUsage},
+		{[]string{"-memory-limit", "4"}, ExitUsage},
+		{[]string{"-memory-limit", "4", "-window", "2"}, ExitUsage},
+		{[]string{filepath.Join(t.TempDir(), "missing")}, ExitIO},
+	}
+
+	for _, tc := range testCases {
+		code, stdout, stderr := run(tc.args...)
+		if code != tc.code {
+			t.Errorf("%v: exit code %d, expected %d", tc.args, code, tc.code)
+		}
+		if stdout != "" || stderr == "" {
+			t.Errorf("%v: expected a message on stderr only, got stdout %q and stderr %q",
+				tc.args, stdout, stderr)
+		}
+	}
+}
+
+func TestRunCheck(t *testing.T) {
+	testCases := []struct {
+		args []string
+		code int
+	}{
+		{[]string{"-check"}, ExitOK},
+		{[]string{"-check", "-w", "3"}, ExitDuplicates},
+		{[]string{"-check", "-i", "-w", "3", "-global"}, ExitDuplicates},
+		{[]string{"-check", "-w", "1", "-bloom"}, ExitDuplicates},
+	}
+
+	for _, tc := range testCases {
+		code, stdout, stderr := run(tc.args...)
+		if code != tc.code || stdout != "" {
+			t.Errorf("%v: exit code %d, stdout %q", tc.args, code, stdout)
+		}
+		if code == ExitDuplicates && !strings.Contains(stderr, "stdin: duplicate line") {
+			t.Errorf("%v: the duplicate is not reported: %q", tc.args, stderr)
+		}
+	}
+}
+
+func TestRunEmpty(t *testing.T) {
+	for _, args := range [][]string{nil, {"-c"}, {"-d"}, {"-u"}, {"-global"}, {"-global", "-sort"}, {"-check"}} {
+		var out, errOut bytes.Buffer
+		code := Run(args, strings.NewReader(""), &out, &errOut)
+		if code != ExitOK || out.Len() != 0 || errOut.Len() != 0 {
+			t.Errorf("%v: exit code %d, stdout %q, stderr %q", args, code, out.String(), errOut.String())
+		}
+	}
+}
+
+func TestRunOversize(t *testing.T) {
+	code, stdout, stderr := run("-max-line", "4", "-oversize", "truncate", "-c")
+	if code != 0 || stdout != "1 AAA \n1 aaa \n2 bbb \n1 ccc \n" {
+		t.Errorf("exit code %d, got %q", code, stdout)
+	}
+	if !strings.Contains(stderr, "5") || !strings.Contains(stderr, "line 1") {
+		t.Errorf("the long lines are not reported: %q", stderr)
+	}
+
+	if code, _, stderr := run("-max-line", "4"); code != ExitIO || !strings.HasPrefix(stderr, "stdin: line 1:") {
+		t.Errorf("exit code %d, stderr %q", code, stderr)
+	}
+	if code, _, _ := run("-oversize", "unknown"); code != ExitUsage {
+		t.Errorf("unknown policy: exit code %d", code)
+	}
+}
+
+func TestRunBloom(t *testing.T) {
+	code, stdout, stderr := run("-bloom", "-w", "3")
+	if code != 0 || stdout != "AAA 1\naaa 2\nbbb 3\nccc 5\n" {
+		t.Errorf("exit code %d, got %q", code, stdout)
+	}
+	if !strings.HasPrefix(stderr, "stdin: estimated false positive rate of -bloom ") {
+		t.Errorf("the false positive rate is not reported: %q", stderr)
+	}
+
+	// -stats reports it once
+	if _, _, stderr := run("-bloom", "-stats"); strings.Count(stderr, "false positive rate") != 1 {
+		t.Errorf("-stats: %q", stderr)
+	}
+}
+
+func TestMergeCounts(t *testing.T) {
+	dir := t.TempDir()
+
+	var files []string
+	for i, input := range []string{"a\nb\na\n", "c\na\n"} {
+		var out, errOut bytes.Buffer
+		if code := Run([]string{"-c", "-global", "-partial"}, strings.NewReader(input), &out, &errOut); code != 0 {
+			t.Fatalf("exit code %d: %s", code, errOut.String())
+		}
+
+		file := filepath.Join(dir, fmt.Sprint(i))
+		if err := os.WriteFile(file, out.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, file)
+	}
+
+	var out, errOut bytes.Buffer
+	code := Run(append([]string{"merge-counts"}, files...), nil, &out, &errOut)
+	if code != 0 || out.String() != "3 a\n1 b\n1 c\n" {
+		t.Errorf("exit code %d, got %q, %s", code, out.String(), errOut.String())
+	}
+
+	if code, _, stderr := run("merge-counts"); code != ExitIO || !strings.HasPrefix(stderr, "stdin: line 1:") {
+		t.Errorf("not a partial counts input: exit code %d, stderr %q", code, stderr)
+	}
+
+	bad := filepath.Join(dir, "bad")
+	if err := os.WriteFile(bad, []byte("#uniq-partial v1\n1\ta\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	errOut.Reset()
+	code = Run([]string{"merge-counts", files[0], bad}, nil, io.Discard, &errOut)
+	if code != ExitIO || !strings.HasPrefix(errOut.String(), bad+": line 2:") {
+		t.Errorf("exit code %d, stderr %q", code, errOut.String())
+	}
+	if code, _, _ := run("-partial"); code != ExitUsage {
+		t.Errorf("-partial without -c: exit code %d", code)
+	}
+}
+
+func TestOutput(t *testing.T) {
+	for _, async := range []bool{false, true} {
+		var buf bytes.Buffer
+
+		out := newOutput(&buf, false, async)
+		io.WriteString(out, "a\n")
+		if buf.Len() != 0 {
+			t.Errorf("async %v: the line is written before Close", async)
+		}
+		if err := out.Close(); err != nil || buf.String() != "a\n" {
+			t.Errorf("async %v: got %q, %v", async, buf.String(), err)
+		}
+	}
+
+	var buf bytes.Buffer
+	out := newOutput(&buf, true, false)
+	out.Write([]byte("a"))
+	out.Write([]byte("\n"))
+	if buf.String() != "a\n" {
+		t.Errorf("the line is not flushed: %q", buf.String())
+	}
+}
+
+func TestOutputError(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+	defer w.Close()
+
+	out := newOutput(w, false, true)
+	out.Write(bytes.Repeat([]byte("a\n"), outputBufferSize))
+	if err := out.Close(); err == nil {
+		t.Error("the error of the async write is lost")
+	}
+}
+
+func TestParse(t *testing.T) {
+	cmd := New()
+	cmd.SetOutput(&bytes.Buffer{})
+
+	if err := cmd.Parse([]string{"-global", "-last", "-i", "in.txt", "out.txt"}); err != nil {
+		t.Fatal(err)
+	}
+	if !cmd.Global || !cmd.KeepLast || !cmd.IgnoreCase {
+		t.Errorf("flags are not set: %+v", cmd)
+	}
+	if cmd.Input != "in.txt" || cmd.Output != "out.txt" {
+		t.Errorf("got input %q and output %q", cmd.Input, cmd.Output)
+	}
+	if err := cmd.Validate(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"uniq/dedup"
+	"uniq/mmap"
+)
+
+// runMerge runs "uniq merge-counts", which merges the partial counts
+// of the files printed by uniq -c -partial
+func runMerge(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	var partial, printStats bool
+
+	fs := flag.NewFlagSet("merge-counts", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, "Usage of merge-counts:\n"+
+			"uniq merge-counts [-partial] [-stats] [file ...]\n"+
+			"if files not specified, then stdin is used\n\n")
+		fs.PrintDefaults()
+	}
+	fs.BoolVar(&partial, "partial", false, "Выводить результат в формате частичных счетчиков")
+	fs.BoolVar(&printStats, "stats", false, "Вывести статистику обработки в stderr")
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return ExitOK
+		}
+		return ExitUsage
+	}
+
+	inputs, names := []io.Reader{stdin}, []string{"stdin"}
+	if fs.NArg() > 0 {
+		names = fs.Args()
+		inputs = inputs[:0]
+		for _, path := range fs.Args() {
+			f, err := mmap.Open(path)
+			if err != nil {
+				fmt.Fprintln(stderr, err)
+				return ExitIO
+			}
+			defer f.Close()
+			inputs = append(inputs, f)
+		}
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	opts := dedup.DefaultOptions()
+	opts.Partial = partial
+
+	out := newOutput(stdout, isTerminal(stdout), false)
+	stats, err := dedup.MergeCounts(ctx, inputs, out, &opts)
+	if e := out.Close(); err == nil {
+		err = e
+	}
+	if printStats {
+		fmt.Fprint(stderr, stats)
+	}
+
+	var inputErr *dedup.InputError
+	if errors.As(err, &inputErr) {
+		err = fmt.Errorf("%s: %w", names[inputErr.Input], inputErr.Err)
+	}
+
+	code := exitCode(ctx, err)
+	if code != ExitOK && code != ExitInterrupted {
+		fmt.Fprintln(stderr, err)
+	}
+	return code
+}
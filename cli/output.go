@@ -0,0 +1,129 @@
+package cli
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/mattn/go-isatty"
+)
+
+// the size of the output buffer
+const outputBufferSize = 64 * 1024
+
+// output buffers the lines written by the command. The lines are flushed
+// when the buffer is full, on Close, and with lineFlush after every line.
+type output struct {
+	buf       *bufio.Writer
+	lineFlush bool
+	async     *asyncWriter
+}
+
+// newOutput returns the buffered output to w. With async the buffers
+// are written to w on a separate goroutine.
+func newOutput(w io.Writer, lineFlush, async bool) *output {
+	o := &output{lineFlush: lineFlush}
+	if async {
+		o.async = newAsyncWriter(w, outputBufferSize)
+		w = o.async
+	}
+	o.buf = bufio.NewWriterSize(w, outputBufferSize)
+	return o
+}
+
+func (o *output) Write(p []byte) (n int, err error) {
+	if n, err = o.buf.Write(p); err == nil && o.lineFlush && len(p) > 0 && p[len(p)-1] == '\n' {
+		err = o.buf.Flush()
+	}
+	return
+}
+
+func (o *output) WriteString(s string) (n int, err error) {
+	if n, err = o.buf.WriteString(s); err == nil && o.lineFlush && len(s) > 0 && s[len(s)-1] == '\n' {
+		err = o.buf.Flush()
+	}
+	return
+}
+
+// Close flushes the buffer and waits until it is written
+func (o *output) Close() error {
+	err := o.buf.Flush()
+	if o.async != nil {
+		if e := o.async.Close(); err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// asyncWriter writes the buffers to w on its own goroutine. The error of
+// a write is returned by the next calls.
+type asyncWriter struct {
+	w     io.Writer
+	queue chan []byte
+	// the buffers that are written or can be filled
+	free chan []byte
+	done chan struct{}
+
+	mu  sync.Mutex
+	err error
+}
+
+func newAsyncWriter(w io.Writer, size int) *asyncWriter {
+	a := &asyncWriter{
+		w:     w,
+		queue: make(chan []byte, 1),
+		free:  make(chan []byte, 2),
+		done:  make(chan struct{}),
+	}
+	for i := 0; i < cap(a.free); i++ {
+		a.free <- make([]byte, 0, size)
+	}
+
+	go a.loop()
+	return a
+}
+
+func (a *asyncWriter) loop() {
+	defer close(a.done)
+
+	for buf := range a.queue {
+		if a.error() == nil {
+			if _, err := a.w.Write(buf); err != nil {
+				a.mu.Lock()
+				a.err = err
+				a.mu.Unlock()
+			}
+		}
+		a.free <- buf[:0]
+	}
+}
+
+func (a *asyncWriter) error() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.err
+}
+
+func (a *asyncWriter) Write(p []byte) (int, error) {
+	if err := a.error(); err != nil {
+		return 0, err
+	}
+	// p is reused by the caller
+	a.queue <- append(<-a.free, p...)
+	return len(p), nil
+}
+
+// Close waits until the queued buffers are written
+func (a *asyncWriter) Close() error {
+	close(a.queue)
+	<-a.done
+	return a.error()
+}
+
+// isTerminal reports whether w is a terminal that shows every line at once
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	return ok && (isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd()))
+}
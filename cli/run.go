@@ -0,0 +1,241 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"uniq/dedup"
+	"uniq/mmap"
+
+	"github.com/fatih/color"
+)
+
+// setReader opens the input file, a regular file is mapped into memory
+func setReader(reader io.Reader, path string) (r io.Reader, err error) {
+	r = reader
+
+	if path != "" {
+		r, err = mmap.Open(path)
+	}
+
+	return
+}
+
+// setWriter creates or truncates the output file
+func setWriter(writer io.Writer, path string) (w io.Writer, err error) {
+	w = writer
+
+	if path != "" {
+		w, err = os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	}
+
+	return
+}
+
+// Exit codes of Run
+const (
+	ExitOK = 0
+	// ExitDuplicates is returned by -check for an input with duplicates
+	ExitDuplicates = 1
+	// ExitUsage is returned for wrong arguments and options
+	ExitUsage = 2
+	// ExitIO is returned when the input can not be read
+	// or the output written
+	ExitIO = 3
+	// ExitInterrupted is returned when a signal stops the processing
+	ExitInterrupted = 130
+)
+
+// errDuplicate stops the processing of -check on the first duplicate
+var errDuplicate = errors.New("duplicate line")
+
+// checkWriter is the output of -check, the first line written to it
+// is a duplicate. A write can have several lines, e.g. the groups
+// of a chunk of -jobs.
+type checkWriter struct{}
+
+func (checkWriter) Write(p []byte) (int, error) {
+	if i := bytes.IndexByte(p, '\n'); i >= 0 {
+		p = p[:i]
+	}
+	return 0, fmt.Errorf("%w %q", errDuplicate, p)
+}
+
+// exitCode returns the exit code for the error of the processing
+func exitCode(ctx context.Context, err error) int {
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.Is(err, errDuplicate):
+		return ExitDuplicates
+	case ctx.Err() != nil:
+		return ExitInterrupted
+	case errors.Is(err, dedup.ErrOptions):
+		return ExitUsage
+	}
+	return ExitIO
+}
+
+// Run runs uniq with the arguments without the program name
+// and returns the exit code
+func Run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) > 0 && args[0] == "merge-counts" {
+		return runMerge(args[1:], stdin, stdout, stderr)
+	}
+
+	cmd := New()
+	cmd.SetOutput(stderr)
+
+	if err := cmd.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return ExitOK
+		}
+		return ExitUsage
+	}
+
+	if err := cmd.Validate(); err != nil {
+		fmt.Fprintln(stderr, err)
+		cmd.Usage()
+		return ExitUsage
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	err := cmd.run(ctx, stdin, stdout, stderr)
+	code := exitCode(ctx, err)
+	if code != ExitOK && code != ExitInterrupted {
+		fmt.Fprintln(stderr, err)
+	}
+	return code
+}
+
+// signalContext returns the context that is done on the first signal,
+// so the processing stops and the output is flushed
+func signalContext() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		// the next signal terminates the program
+		<-ctx.Done()
+		stop()
+	}()
+	return ctx, stop
+}
+
+func (cmd *Cmd) run(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer) error {
+	cmd.setup()
+
+	reader, err := setReader(stdin, cmd.Input)
+	if err != nil {
+		return err
+	}
+	name := "stdin"
+	if cmd.Input != "" {
+		name = cmd.Input
+		defer reader.(io.Closer).Close()
+	}
+
+	lineFlush := cmd.LineBuffered || cmd.Output == "" && isTerminal(stdout)
+	if cmd.Colorize && stdout == io.Writer(os.Stdout) {
+		// colorable output on Windows
+		stdout = color.Output
+	}
+	writer, err := setWriter(stdout, cmd.Output)
+	if err != nil {
+		return err
+	}
+	if cmd.Output != "" {
+		defer writer.(*os.File).Close()
+	}
+
+	//==========================
+	process := dedup.Deduplicate
+	if cmd.Count {
+		process = dedup.Count
+	} else if cmd.Prefix != "" {
+		process = dedup.CountPrefix
+	} else if cmd.Cardinality {
+		process = dedup.Cardinality
+	} else if cmd.Top > 0 {
+		process = dedup.Top
+	} else if cmd.Unique {
+		process = dedup.Unique
+	} else if cmd.Repeated || cmd.Check {
+		process = dedup.Duplicates
+	}
+
+	var stats dedup.Stats
+	if cmd.Check {
+		stats, err = process(ctx, reader, checkWriter{}, &cmd.Options)
+	} else {
+		out := newOutput(writer, lineFlush, cmd.Async)
+		stats, err = process(ctx, reader, out, &cmd.Options)
+		if e := out.Close(); err == nil {
+			err = e
+		}
+	}
+	if cmd.PrintStats {
+		fmt.Fprint(stderr, stats)
+	} else {
+		if o := stats.Oversize; o != nil {
+			fmt.Fprintf(stderr, "%s: %d lines longer than -max-line (-oversize %s), the first is line %d\n",
+				name, o.Lines, cmd.Oversize, o.FirstLine)
+		}
+		// the new lines taken for duplicates are lost silently otherwise
+		if b := stats.Bloom; b != nil {
+			fmt.Fprintf(stderr, "%s: estimated false positive rate of -bloom %g\n",
+				name, b.FalsePositiveRate)
+		}
+	}
+
+	// the errors of the files already have their names
+	var pathErr *os.PathError
+	if err != nil && !errors.As(err, &pathErr) && !errors.Is(err, dedup.ErrOptions) {
+		err = fmt.Errorf("%s: %w", name, err)
+	}
+	return err
+}
+
+// setup sets Key and Fprintln from the parsed options
+func (cmd *Cmd) setup() {
+	var builder strings.Builder
+
+	if len(cmd.pipeline) > 0 {
+		cmd.Key = cmd.pipeline
+	}
+
+	if !cmd.Colorize && !cmd.Range {
+		return
+	}
+
+	cmd.Fprintln = func(writer io.Writer, line string) (err error) {
+		idx := dedup.Substring(line,
+			cmd.NumFields, cmd.SkipChars, cmd.TakeChars,
+		)
+
+		builder.Reset()
+		if cmd.Range {
+			fmt.Fprintf(&builder, "[%d:%d] ", idx[0], idx[1])
+		}
+		if cmd.Colorize {
+			builder.WriteString(line[:idx[0]])
+			builder.WriteString(color.GreenString(line[idx[0]:idx[1]]))
+			builder.WriteString(line[idx[1]:])
+		} else {
+			builder.WriteString(line)
+		}
+		builder.WriteByte('\n')
+
+		// one write of the whole line for the colorable output on Windows
+		_, err = io.WriteString(writer, builder.String())
+		return
+	}
+}
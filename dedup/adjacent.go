@@ -0,0 +1,67 @@
+package dedup
+
+import (
+    "context"
+    "fmt"
+    "io"
+    "strings"
+)
+
+func groupLines(
+    ctx context.Context,
+    reader io.Reader,
+    writer io.Writer,
+    o *Options,
+    m mode,
+    stats *Stats) (err error) {
+    /* Adjacent and in-memory global modes: every mode prints the
+       representative lines of the groups that it accepts */
+
+    it := newIterator(ctx, reader, o)
+    defer func() {
+        s := it.Stats()
+        stats.Lines, stats.Bytes, stats.Groups, stats.Oversize = s.Lines, s.Bytes, s.Groups, s.Oversize
+    }()
+
+    for it.Next() {
+        g := it.Group()
+        if m.accept(g.Count) {
+            if err = m.emit(writer, o, g.Line, g.Count); err != nil {
+                return
+            }
+        }
+    }
+
+    return it.Err()
+}
+
+// CountPrefix prints the number of lines that start with opts.Prefix
+func CountPrefix(
+    ctx context.Context,
+    reader io.Reader,
+    writer io.Writer,
+    opts *Options) (stats Stats, err error) {
+    /*The number of rows in which there is a specified substring*/
+
+    o := opts.normalize()
+    if reader, err = o.shard(reader); err != nil {
+        return
+    }
+    scanner := newLineScanner(ctx, reader, o)
+    cnt := 0
+
+    for scanner.Scan() {
+        line := o.Cutter(o.Mapper(scanner.Text()))
+        if strings.HasPrefix(line, o.Prefix) {
+            cnt += 1
+        }
+    }
+    scanner.count(&stats)
+
+    if err = o.Fprintln(writer, fmt.Sprintf(o.FormatCounter, cnt, o.Prefix)); err != nil {
+        return
+    }
+
+    err = scanner.Err()
+    return
+}
@@ -0,0 +1,75 @@
+package dedup
+
+import (
+    "context"
+    "fmt"
+    "io"
+
+    "uniq/sketch"
+)
+
+func newFilter(o *Options) (f sketch.Filter, err error) {
+    if o.BloomScale {
+        f, err = sketch.NewScalableBloom(o.BloomItems, o.BloomRate)
+    } else {
+        f, err = sketch.NewBloom(o.BloomItems, o.BloomRate)
+    }
+    if err != nil {
+        return nil, fmt.Errorf("%w: %v", ErrOptions, err)
+    }
+    return
+}
+
+func bloomLines(
+    ctx context.Context,
+    reader io.Reader,
+    writer io.Writer,
+    o *Options,
+    m mode,
+    stats *Stats) (err error) {
+    /* Streaming global mode: the seen keys are kept in a Bloom filter,
+       so a new line is dropped as a duplicate with a small probability */
+
+    if m != modeDeduplicate && m != modeDuplicates {
+        return fmt.Errorf("%w: Bloom supports only deduplication and duplicates", ErrOptions)
+    }
+    if o.KeepLast {
+        return fmt.Errorf("%w: Bloom does not support KeepLast", ErrOptions)
+    }
+
+    seen, err := newFilter(o)
+    if err != nil {
+        return
+    }
+    // lines already printed by Duplicates
+    var repeated sketch.Filter
+    if m == modeDuplicates {
+        repeated, _ = newFilter(o)
+    }
+
+    scanner := newLineScanner(ctx, reader, o)
+    defer func() {
+        scanner.count(stats)
+        stats.Groups = int64(seen.Count())
+        stats.Bloom = &BloomStats{FalsePositiveRate: seen.FalsePositiveRate()}
+    }()
+
+    for scanner.Scan() {
+        line := o.Mapper(scanner.Text())
+        key := o.Cutter(line)
+
+        if !seen.TestAndAdd(key) {
+            if m == modeDeduplicate {
+                if err = o.Fprintln(writer, line); err != nil {
+                    return
+                }
+            }
+        } else if m == modeDuplicates && !repeated.TestAndAdd(key) {
+            if err = o.Fprintln(writer, line); err != nil {
+                return
+            }
+        }
+    }
+
+    return scanner.Err()
+}
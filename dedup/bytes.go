@@ -0,0 +1,119 @@
+package dedup
+
+import (
+    "bytes"
+    "context"
+    "fmt"
+    "io"
+    "unicode/utf8"
+)
+
+// lowerBytes appends line in lower case to dst. ASCII lines are lowered
+// without allocations, others like strings.ToLower does.
+func lowerBytes(dst, line []byte) []byte {
+    for _, c := range line {
+        if c >= utf8.RuneSelf {
+            return append(dst, bytes.ToLower(line)...)
+        }
+    }
+
+    for _, c := range line {
+        if 'A' <= c && c <= 'Z' {
+            c += 'a' - 'A'
+        }
+        dst = append(dst, c)
+    }
+    return dst
+}
+
+func bytesLines(
+    ctx context.Context,
+    reader io.Reader,
+    writer io.Writer,
+    o *Options,
+    m mode,
+    stats *Stats) (err error) {
+    /* The adjacent modes for IgnoreCase, NumFields, SkipChars and TakeChars:
+       the lines are compared as bytes in reusable buffers */
+
+    return bytesGroups(ctx, reader, o, stats, func(line, key []byte, cnt int) error {
+        return emitBytes(writer, o, m, line, cnt)
+    })
+}
+
+// emitBytes prints the line of a group of cnt lines if the mode accepts it
+func emitBytes(writer io.Writer, o *Options, m mode, line []byte, cnt int) error {
+    if !m.accept(cnt) {
+        return nil
+    }
+
+    switch {
+    case m == modeCount:
+        return o.Fprintln(writer, fmt.Sprintf(o.FormatCounter, cnt, line))
+    case o.bytesOutput:
+        _, err := writer.Write(append(line, '\n'))
+        return err
+    }
+    return o.Fprintln(writer, string(line))
+}
+
+// bytesGroups calls emit for every group of adjacent lines with equal keys.
+// The line and the key are reused after emit returns.
+func bytesGroups(
+    ctx context.Context,
+    reader io.Reader,
+    o *Options,
+    stats *Stats,
+    emit func(line, key []byte, cnt int) error) (err error) {
+
+    var (
+        // the representative line of the open group and its key
+        prev    []byte
+        prevKey []byte
+        lower   []byte
+        cnt     int
+    )
+
+    scanner := newLineScanner(ctx, reader, o)
+    defer scanner.count(stats)
+
+    for scanner.Scan() {
+        line := scanner.Bytes()
+        if o.IgnoreCase {
+            lower = lowerBytes(lower[:0], line)
+            line = lower
+        }
+
+        idx := substring(line, o.NumFields, o.SkipChars, o.TakeChars)
+        key := line[idx[0]:idx[1]]
+
+        if cnt > 0 && bytes.Equal(key, prevKey) {
+            cnt += 1
+            continue
+        }
+
+        if cnt > 0 {
+            stats.Groups += 1
+            if err = emit(prev, prevKey, cnt); err != nil {
+                return
+            }
+        }
+
+        prev = append(prev[:0], line...)
+        prevKey = prev[idx[0]:idx[1]]
+        cnt = 1
+    }
+
+    if err = ctx.Err(); err != nil {
+        return
+    }
+
+    if cnt > 0 {
+        stats.Groups += 1
+        if err = emit(prev, prevKey, cnt); err != nil {
+            return
+        }
+    }
+
+    return scanner.Err()
+}
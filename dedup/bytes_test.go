@@ -0,0 +1,62 @@
+package dedup
+
+import (
+    "bytes"
+    "context"
+    "math/rand"
+    "strings"
+    "testing"
+)
+
+func TestBytesLines(t *testing.T) {
+    words := []string{"a", "A", "b", "é", "É", "1", "_", "-"}
+    r := rand.New(rand.NewSource(1))
+
+    var lines []string
+    for i := 0; i < 5000; i++ {
+        var b strings.Builder
+        for j := r.Intn(4); j >= 0; j-- {
+            b.WriteString(words[r.Intn(len(words))])
+            if r.Intn(2) == 0 {
+                b.WriteByte(' ')
+            }
+        }
+        lines = append(lines, b.String())
+    }
+    input := strings.Join(lines, "\n")
+
+    modes := map[string]ProcessFunc{
+        "Deduplicate": Deduplicate,
+        "Unique":      Unique,
+        "Duplicates":  Duplicates,
+        "Count":       Count,
+    }
+
+    for name, fn := range modes {
+        for _, opts := range []Options{
+            {},
+            {IgnoreCase: true},
+            {NumFields: 1},
+            {IgnoreCase: true, SkipChars: 1, TakeChars: 2},
+        } {
+            var fast, slow bytes.Buffer
+
+            if _, err := fn(context.Background(), strings.NewReader(input), &fast, &opts); err != nil {
+                t.Fatal(err)
+            }
+
+            // any Mapper makes the lines strings
+            opts.Mapper = strings.ToLower
+            if !opts.IgnoreCase {
+                opts.Mapper = func(s string) string { return s }
+            }
+            if _, err := fn(context.Background(), strings.NewReader(input), &slow, &opts); err != nil {
+                t.Fatal(err)
+            }
+
+            if fast.String() != slow.String() {
+                t.Errorf("%s(%+v) on bytes differs from strings", name, opts)
+            }
+        }
+    }
+}
@@ -0,0 +1,69 @@
+package dedup
+
+import (
+    "context"
+    "fmt"
+    "io"
+
+    "uniq/sketch"
+)
+
+// Cardinality prints the estimated number of distinct lines (HyperLogLog)
+// and the exact one while it does not exceed opts.ExactLimit
+func Cardinality(
+    ctx context.Context,
+    reader io.Reader,
+    writer io.Writer,
+    opts *Options) (stats Stats, err error) {
+
+    o := opts.normalize()
+    if reader, err = o.shard(reader); err != nil {
+        return
+    }
+
+    hll, err := sketch.NewHyperLogLog(o.Precision)
+    if err != nil {
+        err = fmt.Errorf("%w: %v", ErrOptions, err)
+        return
+    }
+
+    var exact map[string]struct{}
+    if o.ExactLimit > 0 {
+        exact = make(map[string]struct{})
+    }
+
+    scanner := newLineScanner(ctx, reader, o)
+
+    for scanner.Scan() {
+        key := o.Cutter(o.Mapper(scanner.Text()))
+        hll.Add(key)
+
+        if exact != nil {
+            exact[key] = struct{}{}
+            if uint(len(exact)) > o.ExactLimit {
+                exact = nil
+            }
+        }
+    }
+    scanner.count(&stats)
+
+    if err = ctx.Err(); err != nil {
+        return
+    }
+
+    stats.Groups = int64(hll.Estimate())
+    if exact != nil {
+        stats.Groups = int64(len(exact))
+    }
+    stats.Duplicates = stats.Lines - stats.Groups
+
+    err = o.Fprintln(writer, fmt.Sprintf(o.FormatCounter, hll.Estimate(),
+        fmt.Sprintf("distinct (±%.2f%%)", 100*hll.Error())))
+    if err == nil && exact != nil {
+        err = o.Fprintln(writer, fmt.Sprintf(o.FormatCounter, len(exact), "distinct (exact)"))
+    }
+    if err == nil {
+        err = scanner.Err()
+    }
+    return
+}
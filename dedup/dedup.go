@@ -0,0 +1,143 @@
+// Package dedup finds repeated lines like uniq(1) does, and also over the
+// whole unsorted input, in a sliding window or in bounded memory.
+//
+// The functions read lines from a reader, print the result to a writer and
+// return the statistics of the input. They never write anywhere else.
+package dedup
+
+import (
+    "context"
+    "fmt"
+    "io"
+)
+
+type mode uint8
+
+const (
+    modeDeduplicate mode = iota
+    modeUnique
+    modeDuplicates
+    modeCount
+)
+
+// accept reports whether a group of cnt equal lines is printed in this mode
+func (m mode) accept(cnt int) bool {
+    switch m {
+    case modeUnique:
+        return cnt == 1
+    case modeDuplicates:
+        return cnt > 1
+    }
+    return true
+}
+
+func (m mode) emit(writer io.Writer, o *Options, line string, cnt int) error {
+    if m == modeCount {
+        return o.Fprintln(writer, fmt.Sprintf(o.FormatCounter, cnt, line))
+    }
+    return o.Fprintln(writer, line)
+}
+
+// ProcessFunc is the signature of Deduplicate, Unique, Duplicates, Count
+// and the other processing functions
+type ProcessFunc func(
+    ctx context.Context,
+    reader io.Reader,
+    writer io.Writer,
+    opts *Options) (Stats, error)
+
+type strategyFunc func(
+    ctx context.Context,
+    reader io.Reader,
+    writer io.Writer,
+    o *Options,
+    m mode,
+    stats *Stats) error
+
+// Deduplicate prints one line of every group of equal lines
+func Deduplicate(
+    ctx context.Context,
+    reader io.Reader,
+    writer io.Writer,
+    opts *Options) (Stats, error) {
+
+    return process(ctx, reader, writer, opts, modeDeduplicate)
+}
+
+// Unique prints only the lines that are not repeated
+func Unique(
+    ctx context.Context,
+    reader io.Reader,
+    writer io.Writer,
+    opts *Options) (Stats, error) {
+
+    return process(ctx, reader, writer, opts, modeUnique)
+}
+
+// Duplicates prints one line of every group of repeated lines
+func Duplicates(
+    ctx context.Context,
+    reader io.Reader,
+    writer io.Writer,
+    opts *Options) (Stats, error) {
+
+    return process(ctx, reader, writer, opts, modeDuplicates)
+}
+
+// Count prefixes lines by the number of occurrences
+func Count(
+    ctx context.Context,
+    reader io.Reader,
+    writer io.Writer,
+    opts *Options) (Stats, error) {
+
+    return process(ctx, reader, writer, opts, modeCount)
+}
+
+func process(
+    ctx context.Context,
+    reader io.Reader,
+    writer io.Writer,
+    opts *Options,
+    m mode) (stats Stats, err error) {
+
+    o := opts.normalize()
+    if reader, err = o.shard(reader); err != nil {
+        return
+    }
+
+    if o.Sort {
+        sorted := sortInput(ctx, reader, o)
+        defer func() {
+            sorted.Close()
+            // the sorted lines always end with a newline and the long
+            // lines are handled, so the input is counted by the sorting
+            stats.Bytes = sorted.stats.Bytes
+            stats.addOversize(sorted.stats.Oversize, 0)
+        }()
+        reader, o = sorted, o.sortedOptions()
+    }
+
+    err = strategy(reader, o, m)(ctx, reader, writer, o, m, &stats)
+    stats.Duplicates = stats.Lines - stats.Groups
+    return
+}
+
+// strategy returns the processing function for the input and the options
+func strategy(reader io.Reader, o *Options, m mode) strategyFunc {
+    switch {
+    case m == modeCount && o.Partial:
+        return partialLines
+    case parallelJobs(reader, o) > 1:
+        return parallelLines
+    case o.isGlobal():
+        return globalLines
+    case o.Window > 0:
+        return windowLines
+    case o.CacheSize > 0 || o.CacheBytes > 0:
+        return cacheLines
+    case o.bytesKey:
+        return bytesLines
+    }
+    return groupLines
+}
@@ -0,0 +1,189 @@
+package dedup
+
+import (
+    "bytes"
+    "context"
+    "errors"
+    "fmt"
+    "io"
+    "os"
+    "strings"
+    "testing"
+)
+
+var testInput = "aaa\nbbb\naaa\nccc\nbbb\naaa\naaa\nddd"
+
+func ExampleDuplicates() {
+    opts := DefaultOptions()
+    opts.Global = true
+
+    stats, err := Duplicates(context.Background(),
+        strings.NewReader(testInput), os.Stdout, &opts)
+    if err != nil {
+        fmt.Println(err)
+    }
+    fmt.Printf("%d lines, %d groups, %d duplicates\n",
+        stats.Lines, stats.Groups, stats.Duplicates)
+    // Output:
+    // aaa
+    // bbb
+    // 8 lines, 4 groups, 4 duplicates
+}
+
+func TestStats(t *testing.T) {
+
+    testCases := []struct {
+        name   string
+        opts   Options
+        groups int64
+    }{
+        {"adjacent", Options{}, 7},
+        {"global", Options{Global: true}, 4},
+        {"spill", Options{Global: true, MemoryLimit: 1}, 4},
+        {"fingerprint", Options{Global: true, Fingerprint: 64}, 4},
+        {"bloom", Options{Bloom: true}, 4},
+        {"window", Options{Window: 2}, 6},
+        {"cache", Options{CacheSize: 2}, 6},
+        {"sort", Options{Sort: true}, 4},
+    }
+
+    for _, tc := range testCases {
+        stats, err := Deduplicate(context.Background(),
+            strings.NewReader(testInput), io.Discard, &tc.opts)
+        if err != nil {
+            t.Fatalf("%s: %v", tc.name, err)
+        }
+
+        if stats.Lines != 8 || stats.Bytes != int64(len(testInput)) {
+            t.Errorf("%s: read %d lines and %d bytes, expected 8 and %d",
+                tc.name, stats.Lines, stats.Bytes, len(testInput))
+        }
+        if stats.Groups != tc.groups || stats.Duplicates != 8-tc.groups {
+            t.Errorf("%s: %d groups and %d duplicates, expected %d and %d",
+                tc.name, stats.Groups, stats.Duplicates, tc.groups, 8-tc.groups)
+        }
+    }
+}
+
+func TestModes(t *testing.T) {
+    input := "a 1\nb 2\nb 3\nc 4\nc 5\nc 6"
+
+    testCases := []struct {
+        name     string
+        fn       func(context.Context, io.Reader, io.Writer, *Options) (Stats, error)
+        expected string
+    }{
+        {"Deduplicate", Deduplicate, "a 1\nb 2\nc 4\n"},
+        {"Unique", Unique, "a 1\n"},
+        {"Duplicates", Duplicates, "b 2\nc 4\n"},
+        // the lines are printed, not their keys
+        {"Count", Count, "1 a 1\n2 b 2\n3 c 4\n"},
+    }
+
+    for _, tc := range testCases {
+        for _, global := range []bool{false, true} {
+            var out bytes.Buffer
+
+            opts := Options{Key: Cut(0, 0, 1), Global: global}
+            if _, err := tc.fn(context.Background(), strings.NewReader(input), &out, &opts); err != nil {
+                t.Fatal(err)
+            }
+            if out.String() != tc.expected {
+                t.Errorf("%s(Global=%v): got %q, expected %q", tc.name, global, out.String(), tc.expected)
+            }
+
+            out.Reset()
+            if _, err := tc.fn(context.Background(), strings.NewReader(""), &out, &opts); err != nil {
+                t.Fatal(err)
+            }
+            if out.Len() != 0 {
+                t.Errorf("%s(Global=%v): printed %q for empty input", tc.name, global, out.String())
+            }
+        }
+    }
+}
+
+func TestNilOptions(t *testing.T) {
+    var out bytes.Buffer
+
+    if _, err := Count(context.Background(), strings.NewReader("a\na\nb"), &out, nil); err != nil {
+        t.Fatal(err)
+    }
+    if out.String() != "2 a\n1 b\n" {
+        t.Errorf("Count with nil options printed %q", out.String())
+    }
+}
+
+func TestOptionsErrors(t *testing.T) {
+    for _, opts := range []Options{
+        {Bloom: true, BloomRate: 2},
+        {Bloom: true, BloomRate: -1, BloomScale: true},
+        {Bloom: true, KeepLast: true},
+        {Global: true, Fingerprint: 32},
+    } {
+        opts := opts
+        if _, err := Deduplicate(context.Background(), strings.NewReader("a\n"), io.Discard, &opts); !errors.Is(err, ErrOptions) {
+            t.Errorf("%+v: got %v", opts, err)
+        }
+    }
+
+    // the input is seekable, so Unique would read it twice
+    opts := Options{Global: true, Fingerprint: 32}
+    if _, err := Unique(context.Background(), strings.NewReader("a\n"), io.Discard, &opts); !errors.Is(err, ErrOptions) {
+        t.Errorf("Unique(%+v): got %v", opts, err)
+    }
+
+    opts = Options{Precision: 2}
+    if _, err := Cardinality(context.Background(), strings.NewReader("a\n"), io.Discard, &opts); !errors.Is(err, ErrOptions) {
+        t.Errorf("Precision 2: got %v", err)
+    }
+}
+
+func TestCanceled(t *testing.T) {
+    ctx, cancel := context.WithCancel(context.Background())
+    cancel()
+
+    var out bytes.Buffer
+    input := strings.Repeat("a\nb\n", 10000)
+
+    for _, opts := range []Options{{}, {Global: true}, {Window: 10}, {Sort: true}} {
+        opts := opts
+        out.Reset()
+
+        _, err := Unique(ctx, strings.NewReader(input), &out, &opts)
+        if !errors.Is(err, context.Canceled) {
+            t.Errorf("Unique(%+v) returned %v, expected context.Canceled", opts, err)
+        }
+    }
+}
+
+type failingWriter struct{}
+
+var errWrite = errors.New("write failed")
+
+func (failingWriter) Write(p []byte) (int, error) {
+    return 0, errWrite
+}
+
+func TestWriteError(t *testing.T) {
+    modes := map[string]func(context.Context, io.Reader, io.Writer, *Options) (Stats, error){
+        "Deduplicate": Deduplicate,
+        "Unique":      Unique,
+        "Duplicates":  Duplicates,
+        "Count":       Count,
+        "CountPrefix": CountPrefix,
+        "Cardinality": Cardinality,
+        "Top":         Top,
+    }
+
+    for name, fn := range modes {
+        for _, opts := range []Options{{Top: 1}, {Global: true, Top: 1}} {
+            opts := opts
+            _, err := fn(context.Background(), strings.NewReader(testInput), failingWriter{}, &opts)
+            if !errors.Is(err, errWrite) {
+                t.Errorf("%s(Global=%v) returned %v, expected the write error",
+                    name, opts.Global, err)
+            }
+        }
+    }
+}
@@ -0,0 +1,152 @@
+package dedup
+
+import (
+    "context"
+    "fmt"
+    "io"
+
+    "uniq/sketch"
+)
+
+type fingerprint [2]uint64
+
+func newFingerprint(key string, bits uint) fingerprint {
+    if bits == 64 {
+        return fingerprint{sketch.Sum64(key)}
+    }
+    return sketch.Sum128(key)
+}
+
+type fpGroup struct {
+    count int
+    // the representative line is either read again from the input
+    // or kept in memory when the input is not seekable or the line
+    // is replaced by the Oversize policy, then offset is -1
+    offset int64
+    length int
+    line   string
+    // another group with the same fingerprint, only with o.Verify
+    next *fpGroup
+}
+
+func fingerprintLines(
+    ctx context.Context,
+    reader io.Reader,
+    writer io.Writer,
+    o *Options,
+    m mode,
+    stats *Stats) (err error) {
+    /* Global mode that stores only fingerprints of the keys. Different keys
+       with equal fingerprints are taken for duplicates unless o.Verify
+       is set: then the line of the group is read again and compared. */
+
+    input, base, seekable := seekableInput(reader)
+    if o.Verify && !seekable {
+        return fmt.Errorf("%w: verifying the fingerprints needs a seekable input", ErrOptions)
+    }
+
+    var (
+        groups []*fpGroup
+        buf    []byte
+        fs     FingerprintStats
+    )
+
+    // the first occurrences can be printed at once
+    streaming := m == modeDeduplicate && !o.KeepLast
+    seen := make(map[fingerprint]*fpGroup)
+
+    represent := func(g *fpGroup, scanner *lineScanner, raw, line string) {
+        if seekable && !scanner.replaced {
+            g.offset, g.length = scanner.offset, len(raw)
+            fs.LineBytes -= int64(len(g.line))
+            g.line = ""
+            return
+        }
+
+        g.offset = -1
+        if seekable || !streaming {
+            fs.LineBytes += int64(len(line) - len(g.line))
+            g.line = line
+        }
+    }
+
+    lineOf := func(g *fpGroup) (line string, err error) {
+        if g.offset < 0 {
+            return g.line, nil
+        }
+        if buf, err = readAt(input, base+g.offset, g.length, buf); err == nil {
+            line = o.Mapper(string(buf))
+        }
+        return
+    }
+
+    scanner := newLineScanner(ctx, reader, o)
+    defer func() {
+        scanner.count(stats)
+        stats.Fingerprint = &fs
+    }()
+
+    for scanner.Scan() {
+        raw := scanner.Text()
+        line := o.Mapper(raw)
+        key := o.Cutter(line)
+        fp := newFingerprint(key, o.Fingerprint)
+
+        g := seen[fp]
+        if g != nil && o.Verify {
+            for ; g != nil; g = g.next {
+                prev, err := lineOf(g)
+                if err != nil {
+                    return err
+                }
+                if o.Cutter(prev) == key {
+                    break
+                }
+            }
+            if g == nil {
+                fs.Collisions += 1
+            }
+        }
+
+        if g != nil {
+            g.count += 1
+            if o.KeepLast {
+                represent(g, scanner, raw, line)
+            }
+            continue
+        }
+
+        g = &fpGroup{count: 1, next: seen[fp]}
+        represent(g, scanner, raw, line)
+        seen[fp] = g
+        stats.Groups += 1
+        fs.FingerprintBytes += int64(o.Fingerprint / 8)
+        fs.KeyBytes += int64(len(key))
+
+        if streaming {
+            if err = o.Fprintln(writer, line); err != nil {
+                return
+            }
+        } else {
+            groups = append(groups, g)
+        }
+    }
+
+    if err = ctx.Err(); err != nil {
+        return
+    }
+
+    for _, g := range groups {
+        if m.accept(g.count) {
+            line, err := lineOf(g)
+            if err != nil {
+                return err
+            }
+            if err = m.emit(writer, o, line, g.count); err != nil {
+                return err
+            }
+        }
+    }
+
+    return scanner.Err()
+}
@@ -0,0 +1,69 @@
+package dedup
+
+import (
+    "context"
+    "fmt"
+    "io"
+
+    "uniq/generic"
+)
+
+type group struct {
+    line  string
+    count int
+    // numbers of the first and the last line of the group
+    first int
+    last  int
+}
+
+func (g *group) merge(other *group, keepLast bool) {
+    if keepLast && other.last > g.last || !keepLast && other.first < g.first {
+        g.line = other.line
+    }
+    if other.first < g.first {
+        g.first = other.first
+    }
+    if other.last > g.last {
+        g.last = other.last
+    }
+    g.count += other.count
+}
+
+// isGlobal reports whether the duplicates are searched over the whole input
+func (o *Options) isGlobal() bool {
+    return o.Global || o.Bloom
+}
+
+// newGrouper returns the grouping engine of the mapped lines
+func newGrouper(o *Options, global bool) *generic.Grouper[string, string] {
+    return generic.NewGrouper(o.Cutter, &generic.Options{
+        Global:   global,
+        KeepLast: global && o.KeepLast,
+    })
+}
+
+func globalLines(
+    ctx context.Context,
+    reader io.Reader,
+    writer io.Writer,
+    o *Options,
+    m mode,
+    stats *Stats) (err error) {
+
+    if o.Fingerprint != 0 && o.Fingerprint != 64 && o.Fingerprint != 128 {
+        return fmt.Errorf("%w: the fingerprints must have 64 or 128 bits", ErrOptions)
+    }
+
+    switch {
+    case o.Bloom:
+        return bloomLines(ctx, reader, writer, o, m, stats)
+    case canTwoPass(reader, o, m):
+        return twoPassLines(ctx, reader, writer, o, m, stats)
+    case o.Fingerprint > 0:
+        return fingerprintLines(ctx, reader, writer, o, m, stats)
+    case o.MemoryLimit > 0:
+        return spillLines(ctx, reader, writer, o, m, stats)
+    }
+
+    return groupLines(ctx, reader, writer, o, m, stats)
+}
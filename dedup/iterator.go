@@ -0,0 +1,168 @@
+package dedup
+
+import (
+    "context"
+    "fmt"
+    "io"
+
+    "uniq/generic"
+)
+
+// Group is a group of lines with equal keys
+type Group struct {
+    Key string
+    // Line is the mapped line that represents the group: the first one,
+    // or the last one with KeepLast
+    Line  string
+    Count int
+    // numbers of the first and the last line of the group, from 1
+    First int
+    Last  int
+}
+
+// Iterator reads the groups of adjacent lines with equal keys, or with
+// Global set, the groups of all equal lines in the order of their first
+// lines. Sort is applied before grouping. Bloom, Fingerprint, MemoryLimit,
+// Window and the cache are not supported.
+//
+//    it := dedup.NewIterator(ctx, reader, &opts)
+//    defer it.Close()
+//    for it.Next() {
+//        g := it.Group()
+//        ...
+//    }
+//    if err := it.Err(); err != nil {
+//        ...
+//    }
+type Iterator struct {
+    o       *Options
+    scanner *lineScanner
+    sorted  *sortedInput
+    stats   Stats
+
+    grouper *generic.Grouper[string, string]
+    // closed groups that are not returned yet
+    groups []generic.Group[string, string]
+    done   bool
+    curr   Group
+
+    err error
+}
+
+// NewIterator returns an iterator over the groups of reader
+func NewIterator(ctx context.Context, reader io.Reader, opts *Options) *Iterator {
+    o := opts.normalize()
+    it := &Iterator{o: o}
+
+    if it.err = o.checkGroups("Iterator"); it.err != nil {
+        return it
+    }
+    if reader, it.err = o.shard(reader); it.err != nil {
+        return it
+    }
+
+    if !o.Sort {
+        return newIterator(ctx, reader, o)
+    }
+
+    sorted := sortInput(ctx, reader, o)
+    it = newIterator(ctx, sorted, o.sortedOptions())
+    it.sorted = sorted
+    return it
+}
+
+// checkGroups reports the options that the groups of Iterator
+// and Partial do not support
+func (o *Options) checkGroups(name string) error {
+    switch {
+    case o.Bloom || o.Fingerprint > 0 || o.MemoryLimit > 0 && o.Global:
+        return fmt.Errorf("%w: %s supports only the in-memory Global mode", ErrOptions, name)
+    case o.Window > 0 || o.CacheSize > 0 || o.CacheBytes > 0:
+        return fmt.Errorf("%w: %s does not support Window and the cache", ErrOptions, name)
+    }
+    return nil
+}
+
+// newIterator returns an iterator over the groups of reader
+// with normalized options without sorting
+func newIterator(ctx context.Context, reader io.Reader, o *Options) *Iterator {
+    return &Iterator{
+        o:       o,
+        scanner: newLineScanner(ctx, reader, o),
+        grouper: newGrouper(o, o.Global),
+    }
+}
+
+// Next advances to the next group and reports whether there is one
+func (it *Iterator) Next() bool {
+    if it.err != nil {
+        return false
+    }
+
+    for len(it.groups) == 0 && !it.done {
+        it.read()
+    }
+
+    if len(it.groups) == 0 {
+        it.err = it.scanner.Err()
+        it.Close()
+        return false
+    }
+
+    g := it.groups[0]
+    it.groups = it.groups[1:]
+    it.curr = Group{Key: g.Key, Line: g.Item, Count: g.Count, First: g.First, Last: g.Last}
+    it.stats.Groups += 1
+    return true
+}
+
+// read reads lines until a group is closed or the input ends
+func (it *Iterator) read() {
+    for it.scanner.Scan() {
+        it.stats.Lines, it.stats.Bytes = it.scanner.lines, it.scanner.next
+        it.stats.Oversize = it.scanner.oversize
+
+        if g, ok := it.grouper.Add(it.o.Mapper(it.scanner.Text())); ok {
+            it.groups = append(it.groups, g)
+            return
+        }
+    }
+
+    it.done = true
+    // the skipped long lines at the end
+    it.stats.Bytes, it.stats.Oversize = it.scanner.next, it.scanner.oversize
+    if it.sorted != nil {
+        it.Close()
+        it.stats.Bytes, it.stats.Oversize = it.sorted.stats.Bytes, it.sorted.stats.Oversize
+    }
+    // the groups of a broken input are not returned
+    if it.scanner.Err() == nil {
+        it.groups = append(it.groups, it.grouper.Close()...)
+    }
+}
+
+// Group returns the current group
+func (it *Iterator) Group() Group {
+    return it.curr
+}
+
+// Err returns the error that stopped the iteration
+func (it *Iterator) Err() error {
+    return it.err
+}
+
+// Stats returns the statistics of the lines read so far
+func (it *Iterator) Stats() Stats {
+    stats := it.stats
+    stats.Duplicates = stats.Lines - stats.Groups
+    return stats
+}
+
+// Close stops the sorting of the input. It is not needed if Next
+// returned false.
+func (it *Iterator) Close() error {
+    if it.sorted != nil {
+        return it.sorted.Close()
+    }
+    return nil
+}
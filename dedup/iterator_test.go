@@ -0,0 +1,107 @@
+package dedup
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "io"
+    "reflect"
+    "strings"
+    "testing"
+)
+
+func ExampleIterator() {
+    opts := DefaultOptions()
+    opts.Global = true
+
+    it := NewIterator(context.Background(), strings.NewReader(testInput), &opts)
+    defer it.Close()
+
+    for it.Next() {
+        g := it.Group()
+        fmt.Printf("%s: %d lines from %d to %d\n", g.Line, g.Count, g.First, g.Last)
+    }
+    if err := it.Err(); err != nil {
+        fmt.Println(err)
+    }
+    // Output:
+    // aaa: 4 lines from 1 to 7
+    // bbb: 2 lines from 2 to 5
+    // ccc: 1 lines from 4 to 4
+    // ddd: 1 lines from 8 to 8
+}
+
+func TestIterator(t *testing.T) {
+    input := "b 1\nB 2\na 3\nb 4\nb 5"
+
+    testCases := []struct {
+        name     string
+        opts     Options
+        expected []Group
+    }{
+        {
+            "adjacent",
+            Options{Mapper: strings.ToLower, Cutter: func(s string) string { return s[:1] }},
+            []Group{{"b", "b 1", 2, 1, 2}, {"a", "a 3", 1, 3, 3}, {"b", "b 4", 2, 4, 5}},
+        },
+        {
+            "global",
+            Options{Global: true, KeepLast: true, Key: Cut(0, 0, 1)},
+            []Group{{"b", "b 5", 3, 1, 5}, {"B", "B 2", 1, 2, 2}, {"a", "a 3", 1, 3, 3}},
+        },
+        {
+            "sort",
+            Options{Sort: true, Key: Cut(0, 0, 1)},
+            []Group{{"B", "B 2", 1, 1, 1}, {"a", "a 3", 1, 2, 2}, {"b", "b 1", 3, 3, 5}},
+        },
+    }
+
+    for _, tc := range testCases {
+        var got []Group
+
+        it := NewIterator(context.Background(), strings.NewReader(input), &tc.opts)
+        for it.Next() {
+            got = append(got, it.Group())
+        }
+        if err := it.Err(); err != nil {
+            t.Fatalf("%s: %v", tc.name, err)
+        }
+
+        if !reflect.DeepEqual(got, tc.expected) {
+            t.Errorf("%s: got %v, expected %v", tc.name, got, tc.expected)
+        }
+
+        stats := it.Stats()
+        if stats.Lines != 5 || stats.Groups != int64(len(tc.expected)) {
+            t.Errorf("%s: got stats %+v", tc.name, stats)
+        }
+    }
+}
+
+func TestIteratorUnsupported(t *testing.T) {
+    it := NewIterator(context.Background(), strings.NewReader("a"), &Options{Window: 2})
+    if it.Next() || it.Err() == nil {
+        t.Errorf("Iterator with Window returned no error")
+    }
+}
+
+func TestNewReader(t *testing.T) {
+    opts := Options{Global: true}
+
+    r := NewReader(context.Background(), strings.NewReader(testInput), &opts, Unique)
+    got, err := io.ReadAll(r)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if string(got) != "ccc\nddd\n" {
+        t.Errorf("got %q", got)
+    }
+
+    r = NewReader(context.Background(), strings.NewReader(testInput), &opts, Duplicates)
+    if err := r.Close(); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := r.Read(make([]byte, 1)); !errors.Is(err, io.ErrClosedPipe) {
+        t.Errorf("Read after Close returned %v", err)
+    }
+}
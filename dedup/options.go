@@ -0,0 +1,252 @@
+package dedup
+
+import (
+    "errors"
+    "fmt"
+    "io"
+    "strings"
+)
+
+// Options of the processing functions. The zero value of a field means
+// its default, so a partially filled Options is valid.
+type Options struct {
+    // IgnoreCase lowers the lines; NumFields, SkipChars and TakeChars make
+    // the key of a line like Substring does. Unlike Mapper and Cutter they
+    // let the adjacent modes work on bytes without allocations.
+    IgnoreCase bool
+    NumFields  uint
+    SkipChars  uint
+    TakeChars  uint
+
+    // Mapper is applied to every line instead of IgnoreCase;
+    // the mapped line is compared and printed
+    Mapper func(string) string
+    // Cutter returns the compared part (the key) of a mapped line
+    // instead of NumFields, SkipChars and TakeChars
+    Cutter func(string) string
+    // Key transforms the key returned by Cutter, e.g. a Chain
+    Key Transformer
+    // Fprintln prints an output line
+    Fprintln func(io.Writer, string) error
+
+    // FormatCounter formats a count and a line, "%d %s" by default
+    FormatCounter string
+    // FormatTop formats a count, its error and a line for Top, "%d ±%d %s" by default
+    FormatTop string
+    // FormatRepeated formats the number of lines suppressed by Writer,
+    // "last message repeated %d times" by default
+    FormatRepeated string
+    // BufferSize is the initial size of the line buffer in kilobytes
+    // if more than 64, the buffer grows for longer lines
+    BufferSize uint
+    // MaxLine limits the length of the lines in bytes if not 0,
+    // the longer lines are handled by the Oversize policy
+    MaxLine  uint
+    Oversize Oversize
+
+    // Prefix is the substring counted by CountPrefix
+    Prefix string
+    // Partial makes Count and MergeCounts print the partial counts
+    // that MergeCounts reads
+    Partial bool
+
+    // Sort sorts the input by key before the adjacent lines are compared
+    Sort bool
+    // Global searches duplicates over the whole input
+    Global bool
+    // KeepLast prints the last line of a group instead of the first one (Global)
+    KeepLast bool
+    // MemoryLimit in kilobytes: Global and Sort spill to temporary files above it
+    MemoryLimit uint
+
+    // Bloom is the Global mode with a Bloom filter of BloomItems items
+    // and the false positive rate BloomRate
+    Bloom      bool
+    BloomItems uint
+    BloomRate  float64
+    // BloomScale adds filters when BloomItems is exceeded
+    BloomScale bool
+
+    // Fingerprint keeps 64 or 128-bit fingerprints of the keys in Global mode
+    Fingerprint uint
+    // Verify reads the input again to compare lines with equal fingerprints
+    Verify bool
+
+    // Window searches duplicates among the last Window lines
+    Window uint
+    // CacheSize and CacheBytes bound the LRU cache of recently seen keys
+    CacheSize  uint
+    CacheBytes uint
+
+    // StartOffset and EndOffset select the lines of a seekable input that
+    // start from StartOffset and before EndOffset (the end if 0)
+    StartOffset int64
+    EndOffset   int64
+
+    // Jobs is the number of goroutines that process parts of a seekable
+    // input in the adjacent and in-memory Global modes. The adjacent modes
+    // with Fprintln are processed sequentially.
+    Jobs uint
+
+    // Precision of the HyperLogLog used by Cardinality, 14 by default
+    Precision uint
+    // ExactLimit is the number of distinct keys Cardinality also counts exactly
+    ExactLimit uint
+
+    // Top is the number of the most frequent lines printed by Top
+    Top uint
+    // TopCapacity is the number of counters of Top, 10*Top by default
+    TopCapacity uint
+
+    // set by normalize when the lines can be processed as bytes
+    // and printed without Fprintln
+    bytesKey    bool
+    bytesOutput bool
+}
+
+// DefaultOptions returns the options with the defaults of the formats
+// and the sketches filled in
+func DefaultOptions() Options {
+    return Options{
+        FormatCounter:  "%d %s",
+        FormatTop:      "%d ±%d %s",
+        FormatRepeated: "last message repeated %d times",
+        BloomItems:     1000000,
+        BloomRate:      0.001,
+        Precision:      14,
+    }
+}
+
+// ErrOptions is wrapped by the errors of the options that can not be
+// used together or with the input
+var ErrOptions = errors.New("invalid options")
+
+func fprintln(w io.Writer, s string) (err error) {
+    if _, err = io.WriteString(w, s); err == nil {
+        _, err = io.WriteString(w, "\n")
+    }
+    return
+}
+
+// normalize returns a copy of o with defaults instead of zero values
+func (o *Options) normalize() *Options {
+    d := DefaultOptions()
+    if o == nil {
+        o = &d
+    }
+
+    c := *o
+    c.bytesKey = c.Mapper == nil && c.Cutter == nil && c.Key == nil
+    c.bytesOutput = c.Fprintln == nil
+
+    if c.Mapper == nil {
+        c.Mapper = func(s string) string { return s }
+        if c.IgnoreCase {
+            c.Mapper = strings.ToLower
+        }
+    }
+    if c.Cutter == nil {
+        c.Cutter = func(s string) string { return s }
+        if c.NumFields > 0 || c.SkipChars > 0 || c.TakeChars > 0 {
+            c.Cutter = Cut(c.NumFields, c.SkipChars, c.TakeChars).Transform
+        }
+    }
+    if c.Key != nil {
+        cut, key := c.Cutter, c.Key
+        c.Cutter = func(s string) string { return key.Transform(cut(s)) }
+        // the copy can be normalized again
+        c.Key = nil
+    }
+    if c.Fprintln == nil {
+        c.Fprintln = fprintln
+    }
+    if c.FormatCounter == "" {
+        c.FormatCounter = d.FormatCounter
+    }
+    if c.FormatTop == "" {
+        c.FormatTop = d.FormatTop
+    }
+    if c.FormatRepeated == "" {
+        c.FormatRepeated = d.FormatRepeated
+    }
+    if c.BloomItems == 0 {
+        c.BloomItems = d.BloomItems
+    }
+    if c.BloomRate == 0 {
+        c.BloomRate = d.BloomRate
+    }
+    if c.Precision == 0 {
+        c.Precision = d.Precision
+    }
+    return &c
+}
+
+// Stats describes a processed input
+type Stats struct {
+    // Lines and Bytes read
+    Lines int64
+    Bytes int64
+    // Groups of lines with equal keys; estimated by Bloom and Cardinality
+    Groups int64
+    // Duplicates is the number of lines repeating an earlier line of their group
+    Duplicates int64
+
+    Bloom       *BloomStats
+    Fingerprint *FingerprintStats
+    Cache       *CacheStats
+    // Oversize counts the lines longer than MaxLine, the skipped
+    // ones are not counted in Lines
+    Oversize *OversizeStats
+}
+
+type BloomStats struct {
+    // FalsePositiveRate is the estimated probability that
+    // a new line was taken for a duplicate
+    FalsePositiveRate float64
+}
+
+type FingerprintStats struct {
+    // bytes of the stored fingerprints and of the keys they replace
+    FingerprintBytes int64
+    KeyBytes         int64
+    // bytes of the lines kept in memory for input that is not seekable
+    LineBytes int64
+    // Collisions of different keys found by Verify
+    Collisions int64
+}
+
+type CacheStats struct {
+    Hits      int64
+    Misses    int64
+    Evictions int64
+}
+
+func (s Stats) String() string {
+    var b strings.Builder
+
+    fmt.Fprintf(&b, "lines: %d, bytes: %d, groups: %d, duplicates: %d\n",
+        s.Lines, s.Bytes, s.Groups, s.Duplicates)
+
+    if s.Bloom != nil {
+        fmt.Fprintf(&b, "bloom: estimated false positive rate %g\n",
+            s.Bloom.FalsePositiveRate)
+    }
+
+    if fp := s.Fingerprint; fp != nil {
+        fmt.Fprintf(&b,
+            "fingerprint: %d bytes of fingerprints instead of %d bytes of keys, %d bytes of lines, %d collisions\n",
+            fp.FingerprintBytes, fp.KeyBytes, fp.LineBytes, fp.Collisions)
+    }
+
+    if c := s.Cache; c != nil {
+        fmt.Fprintf(&b, "cache: %d hits, %d misses, %d evictions\n",
+            c.Hits, c.Misses, c.Evictions)
+    }
+
+    if o := s.Oversize; o != nil {
+        fmt.Fprintf(&b, "oversize: %d lines longer than the limit, the first is line %d\n",
+            o.Lines, o.FirstLine)
+    }
+
+    return b.String()
+}
@@ -0,0 +1,79 @@
+package dedup
+
+import (
+    "errors"
+    "fmt"
+    "unicode/utf8"
+)
+
+// Oversize is the policy for the lines longer than Options.MaxLine
+type Oversize uint8
+
+const (
+    // OversizeError stops the processing with ErrLineTooLong
+    OversizeError Oversize = iota
+    // OversizeSkip ignores the line
+    OversizeSkip
+    // OversizeTruncate cuts the line to MaxLine bytes
+    // (without splitting a UTF-8 character)
+    OversizeTruncate
+    // OversizeHashOnly replaces the line by its length and hash, so equal
+    // long lines are still found without keeping them in memory
+    OversizeHashOnly
+)
+
+var oversizeNames = []string{"error", "skip", "truncate", "hash-only"}
+
+func (p Oversize) String() string {
+    if int(p) < len(oversizeNames) {
+        return oversizeNames[p]
+    }
+    return fmt.Sprintf("Oversize(%d)", p)
+}
+
+// ParseOversize returns the policy by its name: error, skip, truncate or hash-only
+func ParseOversize(name string) (Oversize, error) {
+    for i, n := range oversizeNames {
+        if n == name {
+            return Oversize(i), nil
+        }
+    }
+    return 0, fmt.Errorf("unknown oversize policy %q", name)
+}
+
+// ErrLineTooLong is returned for a line longer than MaxLine with OversizeError
+var ErrLineTooLong = errors.New("line is longer than MaxLine")
+
+// OversizeStats are the statistics of the lines longer than MaxLine
+type OversizeStats struct {
+    Lines int64
+    // FirstLine is the number of the first of them, from 1
+    FirstLine int64
+}
+
+// addOversize adds the long lines of an input part whose first line
+// follows base lines of the input
+func (s *Stats) addOversize(o *OversizeStats, base int64) {
+    if o == nil {
+        return
+    }
+    if s.Oversize == nil {
+        s.Oversize = &OversizeStats{FirstLine: base + o.FirstLine}
+    }
+    s.Oversize.Lines += o.Lines
+}
+
+// truncate cuts the line to n bytes at the start of a UTF-8 character
+func truncate(line []byte, n int) []byte {
+    for i := n; i > 0 && i > n-utf8.UTFMax; i-- {
+        if utf8.RuneStart(line[i]) {
+            return line[:i]
+        }
+    }
+    return line[:n]
+}
+
+// hashOnly returns the line of OversizeHashOnly
+func hashOnly(length int64, sum []byte) []byte {
+    return []byte(fmt.Sprintf("oversize:%d:%x", length, sum))
+}
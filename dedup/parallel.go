@@ -0,0 +1,412 @@
+package dedup
+
+import (
+    "bufio"
+    "bytes"
+    "context"
+    "io"
+    "os"
+    "sort"
+    "sync"
+
+    "uniq/sketch"
+)
+
+// the smallest part of the input that is processed by a goroutine
+const parallelMinChunk = 64 * 1024
+
+// chunk is a line-aligned byte range of the input processed by a goroutine
+type chunk struct {
+    off   int64
+    end   int64
+    stats Stats
+
+    // adjacent modes: the first and the last group, which can continue in
+    // the neighbour chunks, and the printed groups between them
+    first  Group
+    last   Group
+    middle *os.File
+
+    // global modes: the groups in the order of their first lines by shards
+    shards [][]Group
+}
+
+// parallelJobs returns the number of chunks that the input is split into
+// with o.Jobs, or 1 if it is processed sequentially
+func parallelJobs(reader io.Reader, o *Options) int {
+    switch {
+    case o.Jobs < 2:
+        return 1
+    case o.MaxLine > 0:
+        // the long lines are reported with their numbers in the input
+        return 1
+    case o.isGlobal() && (o.Bloom || o.Fingerprint > 0 || o.MemoryLimit > 0):
+        return 1
+    case !o.isGlobal() && !o.bytesOutput:
+        // the groups of the adjacent modes are printed by every goroutine,
+        // and Fprintln may not be safe for concurrent use
+        return 1
+    case o.Window > 0 || o.CacheSize > 0 || o.CacheBytes > 0:
+        return 1
+    }
+
+    _, base, ok := seekableInput(reader)
+    if !ok {
+        return 1
+    }
+    size, err := inputSize(reader, base)
+    if err != nil {
+        return 1
+    }
+
+    jobs := (size - base) / parallelMinChunk
+    if jobs > int64(o.Jobs) {
+        jobs = int64(o.Jobs)
+    }
+    if jobs < 1 {
+        jobs = 1
+    }
+    return int(jobs)
+}
+
+// inputSize returns the size of the seekable input and restores its position
+func inputSize(reader io.Reader, base int64) (size int64, err error) {
+    seeker := reader.(io.Seeker)
+    if size, err = seeker.Seek(0, io.SeekEnd); err != nil {
+        return
+    }
+    _, err = seeker.Seek(base, io.SeekStart)
+    return
+}
+
+// splitLines splits the input from base to size into n ranges that start
+// at the beginnings of lines. A range longer than a line is never split.
+func splitLines(input io.ReaderAt, base, size int64, n int) (bounds []int64, err error) {
+    bounds = append(bounds, base)
+    buf := make([]byte, 4096)
+
+    for k := 1; k < n; k++ {
+        pos := base + (size-base)*int64(k)/int64(n)
+        if pos <= bounds[len(bounds)-1] {
+            continue
+        }
+        // the range starts after the line that contains the byte before pos
+        if pos, err = nextLine(input, pos-1, size, buf); err != nil {
+            return
+        }
+        if pos > bounds[len(bounds)-1] && pos < size {
+            bounds = append(bounds, pos)
+        }
+    }
+
+    return append(bounds, size), nil
+}
+
+// nextLine returns the position after the first newline from off
+func nextLine(input io.ReaderAt, off, size int64, buf []byte) (int64, error) {
+    for off < size {
+        n, err := input.ReadAt(buf, off)
+        if i := bytes.IndexByte(buf[:n], '\n'); i >= 0 {
+            return off + int64(i) + 1, nil
+        }
+        off += int64(n)
+
+        if err == io.EOF {
+            break
+        }
+        if err != nil {
+            return 0, err
+        }
+    }
+    return size, nil
+}
+
+func parallelLines(
+    ctx context.Context,
+    reader io.Reader,
+    writer io.Writer,
+    o *Options,
+    m mode,
+    stats *Stats) (err error) {
+    /* Adjacent and in-memory global modes of a seekable input: the chunks
+       of the input are grouped in parallel and the groups are merged in
+       the order of the input, so the output is that of the sequential run */
+
+    input, base, _ := seekableInput(reader)
+    size, err := inputSize(reader, base)
+    if err != nil {
+        return
+    }
+
+    bounds, err := splitLines(input, base, size, parallelJobs(reader, o))
+    if err != nil {
+        return
+    }
+
+    chunks := make([]*chunk, len(bounds)-1)
+    for i := range chunks {
+        chunks[i] = &chunk{off: bounds[i], end: bounds[i+1]}
+    }
+
+    var dir string
+    if !o.isGlobal() {
+        if dir, err = os.MkdirTemp("", "uniq-jobs-"); err != nil {
+            return
+        }
+        defer func() {
+            for _, c := range chunks {
+                if c.middle != nil {
+                    c.middle.Close()
+                }
+            }
+            os.RemoveAll(dir)
+        }()
+    }
+
+    // the first error stops the other goroutines
+    ctx, cancel := context.WithCancel(ctx)
+    defer cancel()
+
+    var (
+        wg       sync.WaitGroup
+        mu       sync.Mutex
+        firstErr error
+    )
+
+    for _, c := range chunks {
+        wg.Add(1)
+        go func(c *chunk) {
+            defer wg.Done()
+
+            part := section(input, c.off, c.end-c.off)
+            var err error
+            if o.isGlobal() {
+                err = c.global(ctx, part, o, len(chunks))
+            } else {
+                err = c.adjacent(ctx, part, o, m, dir)
+            }
+
+            if err != nil {
+                mu.Lock()
+                if firstErr == nil {
+                    firstErr = err
+                }
+                mu.Unlock()
+                cancel()
+            }
+        }(c)
+    }
+    wg.Wait()
+
+    for _, c := range chunks {
+        stats.Lines += c.stats.Lines
+        stats.Bytes += c.stats.Bytes
+    }
+    if firstErr != nil {
+        return firstErr
+    }
+
+    if o.isGlobal() {
+        err = mergeGlobal(writer, o, m, chunks, stats)
+    } else {
+        err = mergeAdjacent(writer, o, m, chunks, stats)
+    }
+    if err != nil {
+        return
+    }
+
+    // the input is read to the end like by the sequential run
+    _, err = reader.(io.Seeker).Seek(size, io.SeekStart)
+    return
+}
+
+// adjacent groups the lines of the chunk and prints the groups between
+// the first and the last one to a temporary file
+func (c *chunk) adjacent(
+    ctx context.Context,
+    section io.Reader,
+    o *Options,
+    m mode,
+    dir string) (err error) {
+
+    if c.middle, err = os.CreateTemp(dir, "chunk-"); err != nil {
+        return
+    }
+    w := bufio.NewWriter(c.middle)
+
+    var (
+        n       int
+        line    []byte
+        key     []byte
+        pending int
+    )
+
+    emit := func(l, k []byte, cnt int) error {
+        n += 1
+        if n == 1 {
+            c.first = Group{Key: string(k), Line: string(l), Count: cnt}
+            return nil
+        }
+        // the previous group is neither the first nor the last one
+        if n > 2 {
+            if err := emitBytes(w, o, m, line, pending); err != nil {
+                return err
+            }
+        }
+        line, key, pending = append(line[:0], l...), append(key[:0], k...), cnt
+        return nil
+    }
+
+    if o.bytesKey {
+        err = bytesGroups(ctx, section, o, &c.stats, emit)
+    } else {
+        it := newIterator(ctx, section, o)
+        for it.Next() {
+            g := it.Group()
+            if err = emit([]byte(g.Line), []byte(g.Key), g.Count); err != nil {
+                break
+            }
+        }
+        if err == nil {
+            err = it.Err()
+        }
+        s := it.Stats()
+        c.stats.Lines, c.stats.Bytes, c.stats.Groups, c.stats.Oversize = s.Lines, s.Bytes, s.Groups, s.Oversize
+    }
+    if err != nil {
+        return
+    }
+
+    c.last = c.first
+    if n > 1 {
+        c.last = Group{Key: string(key), Line: string(line), Count: pending}
+    }
+    return w.Flush()
+}
+
+// mergeAdjacent joins the last group of every chunk with the first group
+// of the next one if their keys are equal
+func mergeAdjacent(writer io.Writer, o *Options, m mode, chunks []*chunk, stats *Stats) (err error) {
+    var (
+        open   Group
+        opened bool
+    )
+
+    emit := func() error {
+        if !m.accept(open.Count) {
+            return nil
+        }
+        return m.emit(writer, o, open.Line, open.Count)
+    }
+
+    for _, c := range chunks {
+        stats.Groups += c.stats.Groups
+        if c.stats.Groups == 0 {
+            continue
+        }
+
+        if opened && open.Key == c.first.Key {
+            open.Count += c.first.Count
+            stats.Groups -= 1
+        } else {
+            if opened {
+                if err = emit(); err != nil {
+                    return
+                }
+            }
+            open = c.first
+        }
+        opened = true
+
+        if c.stats.Groups > 1 {
+            if err = emit(); err != nil {
+                return
+            }
+            if _, err = c.middle.Seek(0, io.SeekStart); err != nil {
+                return
+            }
+            if _, err = io.Copy(writer, c.middle); err != nil {
+                return
+            }
+            open = c.last
+        }
+    }
+
+    if opened {
+        err = emit()
+    }
+    return
+}
+
+// global groups the lines of the chunk over the whole chunk and
+// distributes the groups into shards by their keys
+func (c *chunk) global(ctx context.Context, section io.Reader, o *Options, shards int) error {
+    c.shards = make([][]Group, shards)
+
+    it := newIterator(ctx, section, o)
+    for it.Next() {
+        g := it.Group()
+        s := sketch.Sum64(g.Key) % uint64(shards)
+        c.shards[s] = append(c.shards[s], g)
+    }
+    c.stats = it.Stats()
+
+    return it.Err()
+}
+
+// chunkGroup is a group with the number of the chunk of its first line
+type chunkGroup struct {
+    Group
+    chunk int
+}
+
+// mergeGlobal merges the groups of the chunks by shards in parallel and
+// prints them in the order of their first lines
+func mergeGlobal(writer io.Writer, o *Options, m mode, chunks []*chunk, stats *Stats) (err error) {
+    shards := make([][]chunkGroup, len(chunks))
+
+    var wg sync.WaitGroup
+    for s := range shards {
+        wg.Add(1)
+        go func(s int) {
+            defer wg.Done()
+
+            seen := make(map[string]int)
+            for i, c := range chunks {
+                for _, g := range c.shards[s] {
+                    if j, ok := seen[g.Key]; ok {
+                        shards[s][j].Count += g.Count
+                        if o.KeepLast {
+                            shards[s][j].Line = g.Line
+                        }
+                        continue
+                    }
+                    seen[g.Key] = len(shards[s])
+                    shards[s] = append(shards[s], chunkGroup{g, i})
+                }
+            }
+        }(s)
+    }
+    wg.Wait()
+
+    var groups []chunkGroup
+    for _, shard := range shards {
+        groups = append(groups, shard...)
+    }
+    sort.Slice(groups, func(i, j int) bool {
+        if groups[i].chunk != groups[j].chunk {
+            return groups[i].chunk < groups[j].chunk
+        }
+        return groups[i].First < groups[j].First
+    })
+
+    stats.Groups = int64(len(groups))
+    for _, g := range groups {
+        if m.accept(g.Count) {
+            if err = m.emit(writer, o, g.Line, g.Count); err != nil {
+                return
+            }
+        }
+    }
+    return
+}
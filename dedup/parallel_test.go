@@ -0,0 +1,141 @@
+package dedup
+
+import (
+    "bytes"
+    "context"
+    "io"
+    "math/rand"
+    "strings"
+    "testing"
+)
+
+func TestParallel(t *testing.T) {
+    words := []string{"a", "A", "b", "c", "1", "2"}
+    r := rand.New(rand.NewSource(1))
+
+    var lines []string
+    for i := 0; i < 100000; i++ {
+        switch {
+        case i > 0 && r.Intn(3) == 0:
+            lines = append(lines, lines[i-1])
+        case i == 50000:
+            // a group longer than a chunk
+            for j := 0; j < 40000; j++ {
+                lines = append(lines, "long group")
+            }
+        default:
+            lines = append(lines, words[r.Intn(len(words))]+" "+words[r.Intn(len(words))])
+        }
+    }
+    // the last line has no newline
+    input := strings.Join(lines, "\n")
+
+    modes := map[string]ProcessFunc{
+        "Deduplicate": Deduplicate,
+        "Unique":      Unique,
+        "Duplicates":  Duplicates,
+        "Count":       Count,
+    }
+
+    for name, fn := range modes {
+        for _, opts := range []Options{
+            {},
+            {IgnoreCase: true, SkipChars: 1},
+            {Mapper: strings.ToUpper},
+            {Global: true},
+            {Global: true, KeepLast: true, IgnoreCase: true},
+        } {
+            var seq bytes.Buffer
+            expected, err := fn(context.Background(), strings.NewReader(input), &seq, &opts)
+            if err != nil {
+                t.Fatal(err)
+            }
+
+            for _, jobs := range []uint{2, 3, 8} {
+                opts.Jobs = jobs
+                var par bytes.Buffer
+
+                reader := strings.NewReader(input)
+                if parallelJobs(reader, opts.normalize()) < 2 {
+                    t.Fatalf("the input of %d bytes is not split", len(input))
+                }
+
+                stats, err := fn(context.Background(), reader, &par, &opts)
+                if err != nil {
+                    t.Fatal(err)
+                }
+                if par.String() != seq.String() {
+                    t.Errorf("%s(%+v): the output differs from the sequential one", name, opts)
+                }
+                if stats != expected {
+                    t.Errorf("%s(%+v): got %+v, expected %+v", name, opts, stats, expected)
+                }
+            }
+            opts.Jobs = 0
+        }
+    }
+}
+
+func TestParallelFprintln(t *testing.T) {
+    var lines []string
+    for i := 0; i < 50000; i++ {
+        lines = append(lines, strings.Repeat("a", i%3)+" "+strings.Repeat("b", i%5))
+    }
+    input := strings.Join(lines, "\n")
+
+    // not safe for concurrent use, like the printer of the command
+    var builder strings.Builder
+    fprintln := func(w io.Writer, line string) error {
+        builder.Reset()
+        builder.WriteString("> ")
+        builder.WriteString(line)
+        builder.WriteByte('\n')
+        _, err := io.WriteString(w, builder.String())
+        return err
+    }
+
+    for _, global := range []bool{false, true} {
+        var seq, par bytes.Buffer
+
+        opts := Options{Global: global, SkipChars: 1, Fprintln: fprintln}
+        if _, err := Count(context.Background(), strings.NewReader(input), &seq, &opts); err != nil {
+            t.Fatal(err)
+        }
+
+        opts.Jobs = 4
+        reader := strings.NewReader(input)
+        // the adjacent groups would be printed by every goroutine
+        if jobs := parallelJobs(reader, opts.normalize()); jobs < 2 == global {
+            t.Errorf("Global=%v: the input is split into %d chunks", global, jobs)
+        }
+        if _, err := Count(context.Background(), reader, &par, &opts); err != nil {
+            t.Fatal(err)
+        }
+        if par.String() != seq.String() {
+            t.Errorf("Global=%v: the output differs from the sequential one", global)
+        }
+    }
+}
+
+func TestSplitLines(t *testing.T) {
+    input := "aaaa\nb\ncccccccccc\nd"
+
+    for n := 1; n < 30; n++ {
+        bounds, err := splitLines(strings.NewReader(input), 0, int64(len(input)), n)
+        if err != nil {
+            t.Fatal(err)
+        }
+
+        var parts []string
+        for i := 1; i < len(bounds); i++ {
+            part := input[bounds[i-1]:bounds[i]]
+            if part == "" || i < len(bounds)-1 && !strings.HasSuffix(part, "\n") {
+                t.Errorf("%d: the range %q is not line-aligned", n, part)
+            }
+            parts = append(parts, part)
+        }
+        if strings.Join(parts, "") != input {
+            t.Errorf("%d: got %q", n, parts)
+        }
+    }
+}
@@ -0,0 +1,333 @@
+package dedup
+
+import (
+    "container/heap"
+    "context"
+    "errors"
+    "fmt"
+    "io"
+    "strconv"
+    "strings"
+)
+
+// The partial counts printed by Count with Partial and read by MergeCounts:
+// a header and a line for every group with its count, key and line quoted
+// like Go strings and separated by tabs. The line is omitted if it equals
+// the key. The groups of a sorted file are in the order of their keys.
+//
+//    #uniq-partial v1 sorted
+//    3	"aaa"
+//    2	"bbb"	"BBB 1"
+const (
+    partialHeader = "#uniq-partial v1"
+    partialSorted = " sorted"
+)
+
+// ErrPartialFormat is returned by MergeCounts for an input that is not
+// a partial counts file
+var ErrPartialFormat = errors.New("malformed partial counts")
+
+// InputError is an error of MergeCounts in one of its inputs
+type InputError struct {
+    // Input is the index of the input
+    Input int
+    Err   error
+}
+
+func (e *InputError) Error() string {
+    return fmt.Sprintf("input %d: %v", e.Input+1, e.Err)
+}
+
+func (e *InputError) Unwrap() error {
+    return e.Err
+}
+
+func partialLines(
+    ctx context.Context,
+    reader io.Reader,
+    writer io.Writer,
+    o *Options,
+    m mode,
+    stats *Stats) (err error) {
+    /* Count with Partial prints the groups with their keys, so the counts
+       of several inputs can be merged by MergeCounts */
+
+    if err = o.checkGroups("Partial"); err != nil {
+        return
+    }
+
+    header := partialHeader
+    if o.Sort {
+        header += partialSorted
+    }
+    if _, err = io.WriteString(writer, header+"\n"); err != nil {
+        return
+    }
+
+    it := newIterator(ctx, reader, o)
+    defer func() {
+        s := it.Stats()
+        stats.Lines, stats.Bytes, stats.Groups, stats.Oversize = s.Lines, s.Bytes, s.Groups, s.Oversize
+    }()
+
+    for it.Next() {
+        g := it.Group()
+        if err = writePartial(writer, g.Count, g.Key, g.Line); err != nil {
+            return
+        }
+    }
+
+    return it.Err()
+}
+
+func writePartial(writer io.Writer, count int, key, line string) error {
+    b := strconv.AppendInt(nil, int64(count), 10)
+    b = append(b, '\t')
+    b = strconv.AppendQuote(b, key)
+    if line != key {
+        b = append(b, '\t')
+        b = strconv.AppendQuote(b, line)
+    }
+    b = append(b, '\n')
+
+    _, err := writer.Write(b)
+    return err
+}
+
+// partialReader reads the groups of a partial counts file
+type partialReader struct {
+    idx     int
+    scanner *lineScanner
+    sorted  bool
+    curr    Group
+    err     error
+}
+
+func newPartialReader(ctx context.Context, idx int, reader io.Reader, o *Options) *partialReader {
+    r := &partialReader{idx: idx, scanner: newLineScanner(ctx, reader, o)}
+
+    switch {
+    case !r.scanner.Scan():
+        r.fail("no header")
+    case r.scanner.Text() == partialHeader:
+    case r.scanner.Text() == partialHeader+partialSorted:
+        r.sorted = true
+    default:
+        r.fail("no header")
+    }
+    return r
+}
+
+// fail sets the error of the scanner or the format error
+func (r *partialReader) fail(reason string) {
+    err := r.scanner.Err()
+    if err == nil {
+        err = fmt.Errorf("line %d: %w: %s", r.scanner.lines, ErrPartialFormat, reason)
+    }
+    r.err = &InputError{Input: r.idx, Err: err}
+}
+
+// next reads the next group and reports whether there is one
+func (r *partialReader) next() bool {
+    if r.err != nil {
+        return false
+    }
+    if !r.scanner.Scan() {
+        if r.scanner.Err() != nil {
+            r.fail("")
+        }
+        return false
+    }
+
+    prev := r.curr.Key
+    var err error
+    if r.curr, err = parsePartial(r.scanner.Text()); err != nil {
+        r.fail(err.Error())
+        return false
+    }
+    if r.sorted && r.curr.Key < prev {
+        r.fail("the keys are not sorted")
+        return false
+    }
+    return true
+}
+
+func parsePartial(record string) (g Group, err error) {
+    fields := strings.SplitN(record, "\t", 2)
+    if len(fields) < 2 {
+        return g, errors.New("no key")
+    }
+    if g.Count, err = strconv.Atoi(fields[0]); err != nil || g.Count < 1 {
+        return g, errors.New("bad count")
+    }
+
+    rest := fields[1]
+    quoted, err := strconv.QuotedPrefix(rest)
+    if err != nil {
+        return g, errors.New("bad key")
+    }
+    g.Key, _ = strconv.Unquote(quoted)
+    g.Line = g.Key
+
+    if rest = rest[len(quoted):]; rest != "" {
+        if rest[0] != '\t' {
+            return g, errors.New("bad line")
+        }
+        if g.Line, err = strconv.Unquote(rest[1:]); err != nil {
+            return g, errors.New("bad line")
+        }
+    }
+    return g, nil
+}
+
+// MergeCounts merges the partial counts of several inputs printed by Count
+// with Partial: the counts of equal keys are added up. If all inputs are
+// sorted they are merged in the order of the keys in constant memory,
+// otherwise the groups are printed in the order of their first lines.
+// The counts are printed like by Count or with Partial in the partial format.
+// The statistics count the groups read as lines.
+func MergeCounts(
+    ctx context.Context,
+    inputs []io.Reader,
+    writer io.Writer,
+    opts *Options) (stats Stats, err error) {
+
+    o := opts.normalize()
+
+    readers := make([]*partialReader, len(inputs))
+    sorted := true
+    for i, input := range inputs {
+        readers[i] = newPartialReader(ctx, i, input, o)
+        if readers[i].err != nil {
+            return stats, readers[i].err
+        }
+        sorted = sorted && readers[i].sorted
+    }
+    defer func() {
+        for _, r := range readers {
+            // the header is not counted
+            stats.Lines += r.scanner.lines - 1
+            stats.Bytes += r.scanner.next
+        }
+        stats.Duplicates = stats.Lines - stats.Groups
+    }()
+
+    if o.Partial {
+        header := partialHeader
+        if sorted {
+            header += partialSorted
+        }
+        if _, err = io.WriteString(writer, header+"\n"); err != nil {
+            return
+        }
+    }
+
+    emit := func(g Group) error {
+        stats.Groups += 1
+        if o.Partial {
+            return writePartial(writer, g.Count, g.Key, g.Line)
+        }
+        return o.Fprintln(writer, fmt.Sprintf(o.FormatCounter, g.Count, g.Line))
+    }
+
+    if sorted {
+        err = mergeSorted(readers, emit)
+    } else {
+        err = mergeUnsorted(readers, emit)
+    }
+    return
+}
+
+// partialHeap is the heap of the readers by their current keys
+type partialHeap []*partialReader
+
+func (h partialHeap) Len() int { return len(h) }
+
+func (h partialHeap) Less(i, j int) bool {
+    if h[i].curr.Key != h[j].curr.Key {
+        return h[i].curr.Key < h[j].curr.Key
+    }
+    return h[i].idx < h[j].idx
+}
+
+func (h partialHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *partialHeap) Push(x interface{}) { *h = append(*h, x.(*partialReader)) }
+
+func (h *partialHeap) Pop() interface{} {
+    old := *h
+    r := old[len(old)-1]
+    *h = old[:len(old)-1]
+    return r
+}
+
+func mergeSorted(readers []*partialReader, emit func(Group) error) (err error) {
+    var h partialHeap
+    for _, r := range readers {
+        if r.next() {
+            h = append(h, r)
+        } else if r.err != nil {
+            return r.err
+        }
+    }
+    heap.Init(&h)
+
+    var (
+        open   Group
+        opened bool
+    )
+
+    for h.Len() > 0 {
+        r := h[0]
+        if opened && r.curr.Key == open.Key {
+            open.Count += r.curr.Count
+        } else {
+            if opened {
+                if err = emit(open); err != nil {
+                    return
+                }
+            }
+            open, opened = r.curr, true
+        }
+
+        if r.next() {
+            heap.Fix(&h, 0)
+            continue
+        }
+        if r.err != nil {
+            return r.err
+        }
+        heap.Pop(&h)
+    }
+
+    if opened {
+        err = emit(open)
+    }
+    return
+}
+
+func mergeUnsorted(readers []*partialReader, emit func(Group) error) (err error) {
+    var groups []Group
+    seen := make(map[string]int)
+
+    for _, r := range readers {
+        for r.next() {
+            if i, ok := seen[r.curr.Key]; ok {
+                groups[i].Count += r.curr.Count
+                continue
+            }
+            seen[r.curr.Key] = len(groups)
+            groups = append(groups, r.curr)
+        }
+        if r.err != nil {
+            return r.err
+        }
+    }
+
+    for _, g := range groups {
+        if err = emit(g); err != nil {
+            return
+        }
+    }
+    return
+}
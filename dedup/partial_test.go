@@ -0,0 +1,90 @@
+package dedup
+
+import (
+    "bytes"
+    "context"
+    "errors"
+    "io"
+    "strings"
+    "testing"
+)
+
+func TestMergeCounts(t *testing.T) {
+    parts := []string{
+        "b 1\na 2\nb\tx 3\n",
+        "c 4\nb 5\na 6\n",
+        "\"q\" 7\nb 8\n",
+    }
+    whole := strings.Join(parts, "")
+
+    for _, opts := range []Options{
+        {Global: true, NumFields: 1},
+        {Global: true, KeepLast: true},
+        {Sort: true, SkipChars: 1},
+        {Sort: true, IgnoreCase: true},
+    } {
+        var expected bytes.Buffer
+        if _, err := Count(context.Background(), strings.NewReader(whole), &expected, &opts); err != nil {
+            t.Fatal(err)
+        }
+
+        var inputs []io.Reader
+        for _, part := range parts {
+            partial := opts
+            partial.Partial = true
+
+            var out bytes.Buffer
+            if _, err := Count(context.Background(), strings.NewReader(part), &out, &partial); err != nil {
+                t.Fatal(err)
+            }
+            inputs = append(inputs, &out)
+        }
+
+        var got bytes.Buffer
+        stats, err := MergeCounts(context.Background(), inputs, &got, nil)
+        if err != nil {
+            t.Fatal(err)
+        }
+        // KeepLast keeps the last line of the first part with the key
+        if !opts.KeepLast && got.String() != expected.String() {
+            t.Errorf("%+v: got %q, expected %q", opts, got.String(), expected.String())
+        }
+        if want, _ := Count(context.Background(), strings.NewReader(whole), io.Discard, &opts); stats.Groups != want.Groups {
+            t.Errorf("%+v: got %d groups, expected %d", opts, stats.Groups, want.Groups)
+        }
+    }
+}
+
+func TestMergeCountsPartial(t *testing.T) {
+    inputs := []io.Reader{
+        strings.NewReader("#uniq-partial v1 sorted\n1\t\"a\"\n2\t\"b\"\t\"B\"\n"),
+        strings.NewReader("#uniq-partial v1 sorted\n3\t\"b\"\n"),
+    }
+
+    var out bytes.Buffer
+    if _, err := MergeCounts(context.Background(), inputs, &out, &Options{Partial: true}); err != nil {
+        t.Fatal(err)
+    }
+    if expected := "#uniq-partial v1 sorted\n1\t\"a\"\n5\t\"b\"\t\"B\"\n"; out.String() != expected {
+        t.Errorf("got %q, expected %q", out.String(), expected)
+    }
+}
+
+func TestMergeCountsErrors(t *testing.T) {
+    for _, input := range []string{
+        "",
+        "1 a\n",
+        "#uniq-partial v1\n1\n",
+        "#uniq-partial v1\nx\t\"a\"\n",
+        "#uniq-partial v1\n1\ta\n",
+        "#uniq-partial v1 sorted\n1\t\"b\"\n1\t\"a\"\n",
+    } {
+        inputs := []io.Reader{strings.NewReader(partialHeader + "\n"), strings.NewReader(input)}
+        _, err := MergeCounts(context.Background(), inputs, io.Discard, nil)
+
+        var inputErr *InputError
+        if !errors.Is(err, ErrPartialFormat) || !errors.As(err, &inputErr) || inputErr.Input != 1 {
+            t.Errorf("%q: got %v", input, err)
+        }
+    }
+}
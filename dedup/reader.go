@@ -0,0 +1,37 @@
+package dedup
+
+import (
+    "context"
+    "io"
+)
+
+type reader struct {
+    *io.PipeReader
+    cancel context.CancelFunc
+}
+
+func (r reader) Close() error {
+    r.cancel()
+    return r.PipeReader.Close()
+}
+
+// NewReader returns the output of process, e.g. Unique, on the input
+// as a stream. Reading returns the error of process after the output.
+// Closing the result stops the processing.
+func NewReader(
+    ctx context.Context,
+    input io.Reader,
+    opts *Options,
+    process ProcessFunc) io.ReadCloser {
+
+    ctx, cancel := context.WithCancel(ctx)
+    pr, pw := io.Pipe()
+
+    go func() {
+        defer cancel()
+        _, err := process(ctx, input, pw, opts)
+        pw.CloseWithError(err)
+    }()
+
+    return reader{PipeReader: pr, cancel: cancel}
+}
@@ -0,0 +1,358 @@
+package dedup
+
+import (
+    "bufio"
+    "bytes"
+    "context"
+    "errors"
+    "fmt"
+    "hash"
+    "hash/fnv"
+    "io"
+)
+
+// the context is checked once per this number of lines
+const ctxCheckLines = 1024
+
+// the initial size of the line buffer, it grows for longer lines
+const scanBufferSize = 64 * 1024
+
+// inMemory is an input with the whole content in memory, like a mapped
+// file. Its lines are scanned in place instead of being copied.
+type inMemory interface {
+    io.Reader
+    io.Seeker
+    // Bytes returns the whole content, the unread part starts
+    // at the current position
+    Bytes() []byte
+}
+
+// memoryInput is a part of an input in memory
+type memoryInput struct {
+    *bytes.Reader
+    data []byte
+}
+
+func (m memoryInput) Bytes() []byte {
+    return m.data
+}
+
+// section returns n bytes of the input from off, in memory
+// if the input is in memory
+func section(input io.ReaderAt, off, n int64) io.Reader {
+    m, ok := input.(inMemory)
+    if !ok {
+        return io.NewSectionReader(input, off, n)
+    }
+
+    data := m.Bytes()
+    end := int64(len(data))
+    if off > end {
+        off = end
+    }
+    if n < end-off {
+        end = off + n
+    }
+    data = data[off:end]
+    return memoryInput{bytes.NewReader(data), data}
+}
+
+// lineScanner reads lines of any length like bufio.Scanner, knows where
+// they start and stops when the context is done. The lines longer than
+// MaxLine are handled by the Oversize policy.
+type lineScanner struct {
+    ctx context.Context
+    err error
+    // offset of the current line from the start of the input
+    offset int64
+    next   int64
+    lines  int64
+    // number of the current line in the input with the skipped ones
+    number int64
+
+    maxLine  int
+    policy   Oversize
+    oversize *OversizeStats
+    // the current line is truncated or replaced by its hash,
+    // so it is not in the input
+    replaced bool
+
+    reader *bufio.Reader
+    buf    []byte
+    line   []byte
+    // the hash of a long line without its last two bytes, which can be
+    // its newline
+    hash hash.Hash
+    held []byte
+    sum  []byte
+
+    // the input in memory and its unread bytes
+    mem  inMemory
+    data []byte
+}
+
+func newLineScanner(ctx context.Context, reader io.Reader, o *Options) *lineScanner {
+    s := &lineScanner{ctx: ctx, maxLine: int(o.MaxLine), policy: o.Oversize}
+
+    if m, ok := reader.(inMemory); ok {
+        if pos, err := m.Seek(0, io.SeekCurrent); err == nil && pos <= int64(len(m.Bytes())) {
+            s.mem, s.data = m, m.Bytes()[pos:]
+            return s
+        }
+    }
+
+    size := scanBufferSize
+    if int(o.BufferSize)*1024 > size {
+        size = int(o.BufferSize) * 1024
+    }
+    s.reader = bufio.NewReaderSize(reader, size)
+    return s
+}
+
+func (s *lineScanner) Scan() bool {
+    for {
+        if s.number%ctxCheckLines == 0 {
+            if s.err = s.ctx.Err(); s.err != nil {
+                return false
+            }
+        }
+
+        var (
+            line         []byte
+            size, length int64
+        )
+        if s.mem != nil {
+            line, size, length = s.readMemory()
+        } else {
+            line, size, length = s.readLine()
+        }
+        if size == 0 {
+            return false
+        }
+
+        s.number += 1
+        s.offset = s.next
+        s.next += size
+        s.replaced = false
+
+        if s.maxLine > 0 && length > int64(s.maxLine) {
+            if s.policy == OversizeError {
+                s.err = fmt.Errorf("line %d: %w (%d bytes)", s.number, ErrLineTooLong, length)
+                return false
+            }
+
+            if s.oversize == nil {
+                s.oversize = &OversizeStats{FirstLine: s.number}
+            }
+            s.oversize.Lines += 1
+
+            switch s.policy {
+            case OversizeSkip:
+                continue
+            case OversizeTruncate:
+                line = truncate(line, s.maxLine)
+                s.replaced = true
+            case OversizeHashOnly:
+                line = hashOnly(length, s.sum)
+                s.replaced = true
+            }
+        }
+
+        s.line = line
+        s.lines += 1
+        return true
+    }
+}
+
+// readMemory returns the next line of the input in memory, its size
+// with the newline and its length
+func (s *lineScanner) readMemory() (line []byte, size, length int64) {
+    if len(s.data) == 0 {
+        // the input is read like by bufio.Scanner
+        if s.err == nil {
+            _, s.err = s.mem.Seek(0, io.SeekEnd)
+        }
+        return
+    }
+
+    advance, line, _ := bufio.ScanLines(s.data, true)
+    s.data = s.data[advance:]
+
+    s.hashLine(line)
+    return line, int64(advance), int64(len(line))
+}
+
+// hashLine hashes the whole line with OversizeHashOnly if it is longer
+// than MaxLine
+func (s *lineScanner) hashLine(line []byte) {
+    if s.maxLine > 0 && len(line) > s.maxLine && s.policy == OversizeHashOnly {
+        s.resetHash()
+        s.hash.Write(line)
+        s.sum = s.hash.Sum(s.sum[:0])
+    }
+}
+
+// readLine reads the next line, its size with the newline and its length.
+// Only MaxLine and two bytes of a longer line are kept.
+func (s *lineScanner) readLine() (line []byte, size, length int64) {
+    s.buf = s.buf[:0]
+    limit := -1
+    if s.maxLine > 0 {
+        limit = s.maxLine + 2
+    }
+    long := false
+
+    for {
+        chunk, err := s.reader.ReadSlice('\n')
+        size += int64(len(chunk))
+
+        // a short line is not copied from the buffer of the reader
+        if size == int64(len(chunk)) && (err == nil || err == io.EOF) && (limit < 0 || len(chunk) <= limit) {
+            line = dropNewline(chunk)
+            // up to two bytes longer than MaxLine
+            s.hashLine(line)
+            return line, size, int64(len(line))
+        }
+
+        switch {
+        case long:
+            s.feed(chunk)
+        case limit < 0 || len(s.buf)+len(chunk) <= limit:
+            s.buf = append(s.buf, chunk...)
+        default:
+            // with OversizeError the rest of the line is only counted
+            long = true
+            n := limit - len(s.buf)
+            s.buf = append(s.buf, chunk[:n]...)
+            s.resetHash()
+            s.feed(s.buf)
+            s.feed(chunk[n:])
+        }
+
+        if err == bufio.ErrBufferFull {
+            continue
+        }
+        if err != nil && err != io.EOF {
+            s.err = err
+            // the error of the sorting has the line of the input
+            if !errors.As(err, new(sortError)) {
+                s.err = fmt.Errorf("line %d: %w", s.number+1, err)
+            }
+            return nil, 0, 0
+        }
+        break
+    }
+
+    if !long {
+        line = dropNewline(s.buf)
+        s.hashLine(line)
+        return line, size, int64(len(line))
+    }
+
+    held := dropNewline(s.held)
+    if s.policy == OversizeHashOnly {
+        s.hash.Write(held)
+        s.sum = s.hash.Sum(s.sum[:0])
+    }
+    return s.buf, size, size - int64(len(s.held)-len(held))
+}
+
+func (s *lineScanner) resetHash() {
+    if s.hash == nil {
+        s.hash = fnv.New128a()
+    }
+    s.hash.Reset()
+    s.held = s.held[:0]
+}
+
+// feed hashes p with OversizeHashOnly, but holds back the last two bytes
+// of the line read so far
+func (s *lineScanner) feed(p []byte) {
+    write := func(b []byte) {
+        if s.policy == OversizeHashOnly {
+            s.hash.Write(b)
+        }
+    }
+
+    if len(p) >= 2 {
+        write(s.held)
+        write(p[:len(p)-2])
+        s.held = append(s.held[:0], p[len(p)-2:]...)
+        return
+    }
+
+    s.held = append(s.held, p...)
+    if n := len(s.held) - 2; n > 0 {
+        write(s.held[:n])
+        s.held = append(s.held[:0], s.held[n:]...)
+    }
+}
+
+// dropNewline drops the newline of the line like bufio.ScanLines does
+func dropNewline(line []byte) []byte {
+    if len(line) > 0 && line[len(line)-1] == '\n' {
+        line = line[:len(line)-1]
+    }
+    if len(line) > 0 && line[len(line)-1] == '\r' {
+        line = line[:len(line)-1]
+    }
+    return line
+}
+
+// Bytes returns the current line. The line of an input in memory
+// must not be modified.
+func (s *lineScanner) Bytes() []byte {
+    return s.line
+}
+
+func (s *lineScanner) Text() string {
+    return string(s.line)
+}
+
+func (s *lineScanner) Err() error {
+    return s.err
+}
+
+// count adds the read lines and bytes to stats
+func (s *lineScanner) count(stats *Stats) {
+    stats.Lines += s.lines
+    stats.Bytes += s.next
+    stats.addOversize(s.oversize, 0)
+}
+
+type readSeekerAt interface {
+    io.ReaderAt
+    io.Seeker
+}
+
+// seekableInput returns the input as io.ReaderAt if it can be read again,
+// and the current position that line offsets are counted from
+func seekableInput(reader io.Reader) (input io.ReaderAt, base int64, ok bool) {
+    rs, ok := reader.(readSeekerAt)
+    if !ok {
+        return nil, 0, false
+    }
+
+    // fails for pipes
+    if base, err := rs.Seek(0, io.SeekCurrent); err == nil {
+        return rs, base, true
+    }
+    return nil, 0, false
+}
+
+// readAt reads n bytes at off into buf
+func readAt(input io.ReaderAt, off int64, n int, buf []byte) ([]byte, error) {
+    if cap(buf) < n {
+        buf = make([]byte, n)
+    }
+    buf = buf[:n]
+
+    read, err := input.ReadAt(buf, off)
+    if read == n {
+        return buf, nil
+    }
+    if err == nil || err == io.EOF {
+        err = errors.New("input was changed while reading")
+    }
+    return buf[:read], err
+}
@@ -0,0 +1,174 @@
+package dedup
+
+import (
+    "bytes"
+    "context"
+    "errors"
+    "fmt"
+    "hash/fnv"
+    "io"
+    "strings"
+    "testing"
+)
+
+func TestScannerInMemory(t *testing.T) {
+    input := strings.Repeat("a\r\nA\nb\n\nb\nc\n", 20000) + "last"
+
+    for _, opts := range []Options{
+        {},
+        {IgnoreCase: true},
+        {Mapper: strings.ToUpper, Global: true},
+        {Global: true, Fingerprint: 64, Verify: true},
+        {Jobs: 4},
+        {StartOffset: 1000, EndOffset: 2000},
+    } {
+        var expected, got bytes.Buffer
+
+        want, err := Count(context.Background(), strings.NewReader(input), &expected, &opts)
+        if err != nil {
+            t.Fatal(err)
+        }
+
+        data := []byte(input)
+        mem := memoryInput{bytes.NewReader(data), data}
+        stats, err := Count(context.Background(), mem, &got, &opts)
+        if err != nil {
+            t.Fatal(err)
+        }
+
+        if got.String() != expected.String() || stats.Lines != want.Lines || stats.Bytes != want.Bytes {
+            t.Errorf("%+v: the input in memory is read differently: %+v, %+v", opts, stats, want)
+        }
+        if opts.EndOffset == 0 {
+            if pos, _ := mem.Seek(0, io.SeekCurrent); pos != int64(len(data)) {
+                t.Errorf("%+v: the input is read to %d", opts, pos)
+            }
+        }
+    }
+}
+
+func TestLongLines(t *testing.T) {
+    long := strings.Repeat("x", 200*1024)
+    input := "a\n" + long + "\r\n" + long + "\nb\n" + long + "y"
+
+    inputs := map[string]func() io.Reader{
+        "stream": func() io.Reader { return strings.NewReader(input) },
+        "memory": func() io.Reader {
+            data := []byte(input)
+            return memoryInput{bytes.NewReader(data), data}
+        },
+    }
+
+    for name, newInput := range inputs {
+        var out bytes.Buffer
+        if _, err := Count(context.Background(), newInput(), &out, nil); err != nil {
+            t.Fatalf("%s: %v", name, err)
+        }
+        if expected := "1 a\n2 " + long + "\n1 b\n1 " + long + "y\n"; out.String() != expected {
+            t.Errorf("%s: long lines are not read", name)
+        }
+
+        for _, tc := range []struct {
+            policy   Oversize
+            expected string
+        }{
+            {OversizeSkip, "1 a\n1 b\n"},
+            {OversizeTruncate, "1 a\n2 xxxxx\n1 b\n1 xxxxx\n"},
+            {OversizeHashOnly, "1 a\n" +
+                "2 oversize:204800:1daef087485b6cca6f666985030f458d\n" +
+                "1 b\n" +
+                "1 oversize:204801:0b4505bbfc7edd171303d6aac3cb133c\n"},
+        } {
+            out.Reset()
+            opts := Options{MaxLine: 5, Oversize: tc.policy}
+            stats, err := Count(context.Background(), newInput(), &out, &opts)
+            if err != nil {
+                t.Fatalf("%s: %v", name, err)
+            }
+            if out.String() != tc.expected {
+                t.Errorf("%s, %s: got %q", name, tc.policy, out.String())
+            }
+            if o := stats.Oversize; o == nil || o.Lines != 3 || o.FirstLine != 2 {
+                t.Errorf("%s, %s: got %+v", name, tc.policy, o)
+            }
+            if stats.Bytes != int64(len(input)) {
+                t.Errorf("%s, %s: %d bytes read", name, tc.policy, stats.Bytes)
+            }
+
+            // the replaced lines are not read again from the input
+            var global, fp bytes.Buffer
+            opts.Global = true
+            if _, err := Count(context.Background(), newInput(), &global, &opts); err != nil {
+                t.Fatalf("%s: %v", name, err)
+            }
+            opts.Fingerprint, opts.Verify = 64, true
+            if _, err := Count(context.Background(), newInput(), &fp, &opts); err != nil {
+                t.Fatalf("%s, %s, fingerprint: %v", name, tc.policy, err)
+            }
+            if fp.String() != global.String() {
+                t.Errorf("%s, %s: fingerprint got %q, expected %q", name, tc.policy, fp.String(), global.String())
+            }
+        }
+
+        opts := Options{MaxLine: 5}
+        _, err := Count(context.Background(), newInput(), io.Discard, &opts)
+        // the length is without the newline
+        if !errors.Is(err, ErrLineTooLong) || !strings.HasPrefix(err.Error(), "line 2:") ||
+            !strings.HasSuffix(err.Error(), "(204800 bytes)") {
+            t.Errorf("%s: got %v", name, err)
+        }
+
+        // the sorting handles the long lines of the input
+        out.Reset()
+        opts = Options{MaxLine: 5, Oversize: OversizeSkip, Sort: true}
+        stats, err := Count(context.Background(), newInput(), &out, &opts)
+        if err != nil || out.String() != "1 a\n1 b\n" {
+            t.Errorf("%s, sorted: got %q, %v", name, out.String(), err)
+        }
+        if o := stats.Oversize; o == nil || o.Lines != 3 || o.FirstLine != 2 || stats.Bytes != int64(len(input)) {
+            t.Errorf("%s, sorted: got %+v, %+v", name, stats, o)
+        }
+
+        it := NewIterator(context.Background(), newInput(), &opts)
+        for it.Next() {
+        }
+        if o := it.Stats().Oversize; it.Err() != nil || o == nil || o.Lines != 3 || it.Stats().Bytes != int64(len(input)) {
+            t.Errorf("%s, sorted iterator: got %+v, %v", name, it.Stats(), it.Err())
+        }
+
+        opts = Options{MaxLine: 5, Sort: true}
+        _, err = Count(context.Background(), newInput(), io.Discard, &opts)
+        if !errors.Is(err, ErrLineTooLong) || !strings.HasPrefix(err.Error(), "line 2: line is longer") {
+            t.Errorf("%s, sorted: got %v", name, err)
+        }
+    }
+
+    // the lines up to two bytes longer than MaxLine are read
+    // without the hash of the longer ones
+    sum := func(line string) string {
+        h := fnv.New128a()
+        h.Write([]byte(line))
+        return fmt.Sprintf("oversize:%d:%x", len(line), h.Sum(nil))
+    }
+    for _, n := range []int{6, 7} {
+        a, b := strings.Repeat("a", n), strings.Repeat("b", n)
+        expected := "2 " + sum(a) + "\n1 " + sum(b) + "\n"
+
+        for _, end := range []string{"", "\n"} {
+            input := a + "\n" + b + "\n" + a + end
+            for name, newInput := range map[string]io.Reader{
+                "pipe": strings.NewReader(input),
+                "file": memoryInput{bytes.NewReader([]byte(input)), []byte(input)},
+            } {
+                var out bytes.Buffer
+                opts := Options{MaxLine: 5, Oversize: OversizeHashOnly, Global: true}
+                if _, err := Count(context.Background(), newInput, &out, &opts); err != nil {
+                    t.Fatalf("%s: %v", name, err)
+                }
+                if out.String() != expected {
+                    t.Errorf("%s, MaxLine+%d, end %q: got %q", name, n-5, end, out.String())
+                }
+            }
+        }
+    }
+}
@@ -0,0 +1,57 @@
+package dedup
+
+import (
+    "fmt"
+    "io"
+)
+
+// shard returns the part of the input with the lines that start from
+// StartOffset and before EndOffset, so the shards of several processes
+// have every line once. The offsets are counted from the current position
+// of a seekable input.
+func (o *Options) shard(reader io.Reader) (io.Reader, error) {
+    if o.StartOffset == 0 && o.EndOffset == 0 {
+        return reader, nil
+    }
+    if o.StartOffset < 0 || o.EndOffset < 0 {
+        return nil, fmt.Errorf("%w: the start and end offsets must not be negative", ErrOptions)
+    }
+
+    input, base, ok := seekableInput(reader)
+    if !ok {
+        return nil, fmt.Errorf("%w: the start and end offsets need a seekable input", ErrOptions)
+    }
+    size, err := inputSize(reader, base)
+    if err != nil {
+        return nil, err
+    }
+
+    buf := make([]byte, 4096)
+    // a line belongs to the shard in which it starts
+    lineStart := func(off int64) (int64, error) {
+        switch {
+        case off == base:
+            return base, nil
+        case off >= size:
+            return size, nil
+        }
+        return nextLine(input, off-1, size, buf)
+    }
+
+    start, err := lineStart(base + o.StartOffset)
+    if err != nil {
+        return nil, err
+    }
+
+    end := size
+    if o.EndOffset > 0 {
+        if end, err = lineStart(base + o.EndOffset); err != nil {
+            return nil, err
+        }
+    }
+    if end < start {
+        end = start
+    }
+
+    return section(input, start, end-start), nil
+}
@@ -0,0 +1,48 @@
+package dedup
+
+import (
+    "bytes"
+    "context"
+    "errors"
+    "io"
+    "strings"
+    "testing"
+)
+
+func TestShard(t *testing.T) {
+    input := "aaaa\nb\n\ncccccccccc\nd\ne"
+
+    for size := int64(1); size <= int64(len(input))+1; size++ {
+        var (
+            out   bytes.Buffer
+            lines int64
+        )
+
+        for start := int64(0); start < int64(len(input)); start += size {
+            opts := Options{StartOffset: start, EndOffset: start + size}
+            stats, err := Deduplicate(context.Background(), strings.NewReader(input), &out, &opts)
+            if err != nil {
+                t.Fatal(err)
+            }
+            lines += stats.Lines
+        }
+
+        if out.String() != input+"\n" || lines != 6 {
+            t.Errorf("shards of %d bytes: got %q in %d lines", size, out.String(), lines)
+        }
+    }
+}
+
+func TestShardErrors(t *testing.T) {
+    for _, opts := range []Options{{StartOffset: -1}, {EndOffset: -1}} {
+        if _, err := Deduplicate(context.Background(), strings.NewReader("a\n"), io.Discard, &opts); !errors.Is(err, ErrOptions) {
+            t.Errorf("%+v: got %v", opts, err)
+        }
+    }
+
+    // a pipe can not be split
+    opts := Options{StartOffset: 1}
+    if _, err := Deduplicate(context.Background(), io.MultiReader(strings.NewReader("a\n")), io.Discard, &opts); !errors.Is(err, ErrOptions) {
+        t.Errorf("an input that is not seekable: got %v", err)
+    }
+}
@@ -0,0 +1,268 @@
+package dedup
+
+import (
+    "bufio"
+    "container/heap"
+    "context"
+    "io"
+    "os"
+    "sort"
+    "strings"
+)
+
+// size of the in-memory chunk when o.MemoryLimit is not set
+const sortChunkSize = 64 << 20
+
+type sortLine struct {
+    key  string
+    line string
+}
+
+type sortRun struct {
+    r    *bufio.Reader
+    curr sortLine
+    // runs are numbered in input order so equal keys keep their order
+    idx int
+}
+
+type runHeap []*sortRun
+
+func (h runHeap) Len() int { return len(h) }
+func (h runHeap) Less(i, j int) bool {
+    if h[i].curr.key != h[j].curr.key {
+        return h[i].curr.key < h[j].curr.key
+    }
+    return h[i].idx < h[j].idx
+}
+func (h runHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *runHeap) Push(x interface{}) { *h = append(*h, x.(*sortRun)) }
+func (h *runHeap) Pop() interface{} {
+    old := *h
+    n := len(old)
+    x := old[n-1]
+    *h = old[:n-1]
+    return x
+}
+
+func (run *sortRun) next(o *Options) (err error) {
+    line, err := run.r.ReadString('\n')
+    if err == io.EOF && line != "" {
+        err = nil
+    }
+    if err != nil {
+        return
+    }
+
+    line = strings.TrimSuffix(line, "\n")
+    run.curr = sortLine{key: o.Cutter(o.Mapper(line)), line: line}
+    return
+}
+
+func writeLines(writer io.Writer, lines []sortLine) (err error) {
+    w := bufio.NewWriter(writer)
+    for _, l := range lines {
+        w.WriteString(l.line)
+        if err = w.WriteByte('\n'); err != nil {
+            return
+        }
+    }
+    return w.Flush()
+}
+
+// SortLines returns the lines of reader ordered by the key that
+// opts.Mapper and opts.Cutter produce, so that the adjacent modes find
+// all duplicates. Chunks that do not fit into memory are sorted into
+// temporary files and merged. Closing the result stops the sorting.
+func SortLines(ctx context.Context, reader io.Reader, opts *Options) io.ReadCloser {
+    return sortInput(ctx, reader, opts.normalize())
+}
+
+// sortedInput is the sorted lines of an input
+type sortedInput struct {
+    *io.PipeReader
+    cancel context.CancelFunc
+    done   chan struct{}
+    // stats of the input read by the sorting, set when it is done
+    stats Stats
+}
+
+// sortError is an error of the sorting, it has the number of its line
+// in the input
+type sortError struct {
+    error
+}
+
+func (e sortError) Unwrap() error {
+    return e.error
+}
+
+func sortInput(ctx context.Context, reader io.Reader, o *Options) *sortedInput {
+    pr, pw := io.Pipe()
+    ctx, cancel := context.WithCancel(ctx)
+    s := &sortedInput{PipeReader: pr, cancel: cancel, done: make(chan struct{})}
+
+    go func() {
+        defer close(s.done)
+        if err := sortLines(ctx, reader, pw, o, &s.stats); err != nil {
+            pw.CloseWithError(sortError{err})
+        } else {
+            pw.Close()
+        }
+    }()
+
+    return s
+}
+
+// Close stops the sorting and waits for it, then the stats are set
+func (s *sortedInput) Close() error {
+    s.cancel()
+    err := s.PipeReader.Close()
+    <-s.done
+    return err
+}
+
+// sortedOptions returns the options of the sorted lines: the long lines
+// are already handled by the sorting
+func (o *Options) sortedOptions() *Options {
+    c := *o
+    c.MaxLine = 0
+    return &c
+}
+
+func sortLines(
+    ctx context.Context,
+    reader io.Reader,
+    writer io.Writer,
+    o *Options,
+    stats *Stats) (err error) {
+
+    var (
+        dir   string
+        files []*os.File
+        runs  []*sortRun
+        chunk []sortLine
+        size  uint
+    )
+
+    limit := uint(sortChunkSize)
+    if o.MemoryLimit > 0 {
+        limit = o.MemoryLimit * 1024
+    }
+
+    defer func() {
+        for _, f := range files {
+            f.Close()
+        }
+        if dir != "" {
+            os.RemoveAll(dir)
+        }
+    }()
+
+    flush := func() (err error) {
+        sort.SliceStable(chunk, func(i, j int) bool {
+            return chunk[i].key < chunk[j].key
+        })
+
+        if dir == "" {
+            if dir, err = os.MkdirTemp("", "uniq-sort-"); err != nil {
+                return
+            }
+        }
+
+        f, err := os.CreateTemp(dir, "run-")
+        if err != nil {
+            return
+        }
+        files = append(files, f)
+
+        if err = writeLines(f, chunk); err != nil {
+            return
+        }
+        if _, err = f.Seek(0, io.SeekStart); err != nil {
+            return
+        }
+
+        runs = append(runs, &sortRun{r: bufio.NewReader(f), idx: len(runs)})
+        chunk, size = chunk[:0], 0
+        return
+    }
+
+    scanner := newLineScanner(ctx, reader, o)
+    defer scanner.count(stats)
+
+    for scanner.Scan() {
+        line := scanner.Text()
+        chunk = append(chunk, sortLine{key: o.Cutter(o.Mapper(line)), line: line})
+        size += uint(len(line)) + groupOverhead
+
+        if size > limit {
+            if err = flush(); err != nil {
+                return
+            }
+        }
+    }
+
+    if err = scanner.Err(); err != nil {
+        return
+    }
+
+    if len(runs) == 0 {
+        sort.SliceStable(chunk, func(i, j int) bool {
+            return chunk[i].key < chunk[j].key
+        })
+        return writeLines(writer, chunk)
+    }
+
+    if len(chunk) > 0 {
+        if err = flush(); err != nil {
+            return
+        }
+    }
+
+    return mergeRuns(ctx, writer, runs, o)
+}
+
+func mergeRuns(
+    ctx context.Context,
+    writer io.Writer,
+    runs []*sortRun,
+    o *Options) (err error) {
+    /* k-way merge of the sorted runs */
+
+    h := make(runHeap, 0, len(runs))
+    for _, run := range runs {
+        if err = run.next(o); err == nil {
+            h = append(h, run)
+        } else if err != io.EOF {
+            return
+        }
+    }
+    heap.Init(&h)
+
+    w := bufio.NewWriter(writer)
+    n := 0
+
+    for h.Len() > 0 {
+        if n += 1; n%ctxCheckLines == 0 {
+            if err = ctx.Err(); err != nil {
+                return
+            }
+        }
+
+        run := h[0]
+        w.WriteString(run.curr.line)
+        if err = w.WriteByte('\n'); err != nil {
+            return
+        }
+
+        if err = run.next(o); err == io.EOF {
+            heap.Pop(&h)
+        } else if err != nil {
+            return
+        } else {
+            heap.Fix(&h, 0)
+        }
+    }
+
+    return w.Flush()
+}
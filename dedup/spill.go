@@ -0,0 +1,403 @@
+package dedup
+
+import (
+    "bufio"
+    "container/heap"
+    "context"
+    "encoding/binary"
+    "io"
+    "math"
+    "os"
+    "sort"
+
+    "uniq/sketch"
+)
+
+const (
+    spillBuckets = 64
+    // the most buckets a bucket larger than the memory limit is split into
+    maxSplit = 256
+    // approximate cost of a group in memory besides the line itself
+    groupOverhead = 96
+)
+
+// bucket is a temporary file of groups. The bucket of a key is its hash
+// divided by div modulo the number of the buckets it was split into.
+type bucket struct {
+    file   *os.File
+    writer *bufio.Writer
+    size   int64
+    div, n uint64
+}
+
+// spill partitions groups by key into temporary bucket files
+type spill struct {
+    dir     string
+    limit   uint
+    buckets []*bucket
+    buf     [4 * binary.MaxVarintLen64]byte
+}
+
+func newSpill(limit uint) (s *spill, err error) {
+    s = &spill{limit: limit}
+
+    if s.dir, err = os.MkdirTemp("", "uniq-spill-"); err != nil {
+        return nil, err
+    }
+
+    if _, err = s.create(spillBuckets, 1); err != nil {
+        s.close()
+        return nil, err
+    }
+    return
+}
+
+// create adds n buckets that divide the hash by div
+func (s *spill) create(n int, div uint64) ([]*bucket, error) {
+    buckets := make([]*bucket, n)
+    for i := range buckets {
+        f, err := os.CreateTemp(s.dir, "bucket-")
+        if err != nil {
+            return nil, err
+        }
+        buckets[i] = &bucket{file: f, writer: bufio.NewWriter(f), div: div, n: uint64(n)}
+        s.buckets = append(s.buckets, buckets[i])
+    }
+    return buckets, nil
+}
+
+func (s *spill) close() {
+    for _, b := range s.buckets {
+        if b != nil {
+            b.file.Close()
+        }
+    }
+    os.RemoveAll(s.dir)
+}
+
+func (s *spill) add(key string, g *group) error {
+    return s.write(s.buckets[sketch.Sum64(key)%spillBuckets], g)
+}
+
+func (s *spill) write(b *bucket, g *group) (err error) {
+    n := binary.PutUvarint(s.buf[:], uint64(g.first))
+    n += binary.PutUvarint(s.buf[n:], uint64(g.last))
+    n += binary.PutUvarint(s.buf[n:], uint64(g.count))
+    n += binary.PutUvarint(s.buf[n:], uint64(len(g.line)))
+
+    if _, err = b.writer.Write(s.buf[:n]); err == nil {
+        _, err = b.writer.WriteString(g.line)
+    }
+    b.size += int64(n + len(g.line))
+    return
+}
+
+func readGroup(r *bufio.Reader) (g *group, err error) {
+    var v [4]uint64
+
+    for i := range v {
+        if v[i], err = binary.ReadUvarint(r); err != nil {
+            if i > 0 && err == io.EOF {
+                err = io.ErrUnexpectedEOF
+            }
+            return nil, err
+        }
+    }
+
+    line := make([]byte, v[3])
+    if _, err = io.ReadFull(r, line); err != nil {
+        return nil, err
+    }
+
+    g = &group{
+        line:  string(line),
+        first: int(v[0]),
+        last:  int(v[1]),
+        count: int(v[2]),
+    }
+    return
+}
+
+func (b *bucket) rewind() (r *bufio.Reader, err error) {
+    if err = b.writer.Flush(); err != nil {
+        return
+    }
+    if _, err = b.file.Seek(0, io.SeekStart); err != nil {
+        return
+    }
+    return bufio.NewReader(b.file), nil
+}
+
+// truncate empties the bucket for writing
+func (b *bucket) truncate() (err error) {
+    if err = b.file.Truncate(0); err != nil {
+        return
+    }
+    if _, err = b.file.Seek(0, io.SeekStart); err != nil {
+        return
+    }
+    b.writer.Reset(b.file)
+    b.size = 0
+    return
+}
+
+// splittable reports whether the hash has enough bits
+// to split the bucket
+func (b *bucket) splittable() bool {
+    return b.div <= math.MaxUint64/b.n/maxSplit
+}
+
+// split moves the groups of the i-th bucket to new buckets by the next
+// bits of the hash of their keys, which are reduced later
+func (s *spill) split(i int, o *Options) (err error) {
+    b := s.buckets[i]
+    n := uint64(b.size)/uint64(s.limit+1) + 2
+    if n > maxSplit {
+        n = maxSplit
+    }
+    div := b.div * b.n
+
+    r, err := b.rewind()
+    if err != nil {
+        return
+    }
+    buckets, err := s.create(int(n), div)
+    if err != nil {
+        return
+    }
+
+    for {
+        g, err := readGroup(r)
+        if err == io.EOF {
+            break
+        } else if err != nil {
+            return err
+        }
+        if err = s.write(buckets[sketch.Sum64(o.Cutter(g.line))/div%n], g); err != nil {
+            return err
+        }
+    }
+
+    s.buckets[i] = nil
+    b.file.Close()
+    return os.Remove(b.file.Name())
+}
+
+// reduce merges the groups of a bucket and replaces its content
+// with the groups accepted by the mode, ordered by the first line.
+// A bucket whose groups do not fit into the limit is split instead.
+func (s *spill) reduce(i int, o *Options, m mode, stats *Stats) (err error) {
+    b := s.buckets[i]
+    r, err := b.rewind()
+    if err != nil {
+        return
+    }
+
+    seen := make(map[string]*group)
+    var (
+        groups []*group
+        size   uint
+    )
+    // the groups of keys with the same hash are kept in memory
+    splittable := b.splittable()
+
+    for {
+        g, err := readGroup(r)
+        if err == io.EOF {
+            break
+        } else if err != nil {
+            return err
+        }
+
+        key := o.Cutter(g.line)
+        if prev, ok := seen[key]; ok {
+            size -= uint(len(prev.line))
+            prev.merge(g, o.KeepLast)
+            size += uint(len(prev.line))
+            continue
+        }
+
+        seen[key] = g
+        groups = append(groups, g)
+        size += uint(len(g.line)) + groupOverhead
+
+        if size > s.limit && splittable {
+            return s.split(i, o)
+        }
+    }
+    stats.Groups += int64(len(groups))
+
+    sort.Slice(groups, func(a, b int) bool {
+        return groups[a].first < groups[b].first
+    })
+
+    if err = b.truncate(); err != nil {
+        return
+    }
+    for _, g := range groups {
+        if m.accept(g.count) {
+            if err = s.write(b, g); err != nil {
+                return
+            }
+        }
+    }
+    return
+}
+
+type bucketReader struct {
+    r *bufio.Reader
+    g *group
+}
+
+type bucketHeap []*bucketReader
+
+func (h bucketHeap) Len() int            { return len(h) }
+func (h bucketHeap) Less(i, j int) bool  { return h[i].g.first < h[j].g.first }
+func (h bucketHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *bucketHeap) Push(x interface{}) { *h = append(*h, x.(*bucketReader)) }
+func (h *bucketHeap) Pop() interface{} {
+    old := *h
+    n := len(old)
+    x := old[n-1]
+    *h = old[:n-1]
+    return x
+}
+
+// emit reduces every bucket and prints the groups in original order
+func (s *spill) emit(
+    ctx context.Context,
+    writer io.Writer,
+    o *Options,
+    m mode,
+    stats *Stats) (err error) {
+
+    h := make(bucketHeap, 0, spillBuckets)
+
+    // the buckets split by reduce are added to the end
+    for i := 0; i < len(s.buckets); i++ {
+        if err = ctx.Err(); err != nil {
+            return
+        }
+        if err = s.reduce(i, o, m, stats); err != nil {
+            return
+        }
+        if s.buckets[i] == nil {
+            continue
+        }
+
+        r, err := s.buckets[i].rewind()
+        if err != nil {
+            return err
+        }
+
+        g, err := readGroup(r)
+        if err == io.EOF {
+            continue
+        } else if err != nil {
+            return err
+        }
+        h = append(h, &bucketReader{r: r, g: g})
+    }
+    heap.Init(&h)
+
+    for h.Len() > 0 {
+        b := h[0]
+        if err = m.emit(writer, o, b.g.line, b.g.count); err != nil {
+            return
+        }
+
+        if b.g, err = readGroup(b.r); err == io.EOF {
+            heap.Pop(&h)
+        } else if err != nil {
+            return
+        } else {
+            heap.Fix(&h, 0)
+        }
+    }
+
+    return nil
+}
+
+func spillLines(
+    ctx context.Context,
+    reader io.Reader,
+    writer io.Writer,
+    o *Options,
+    m mode,
+    stats *Stats) (err error) {
+    /* Global grouping that moves the groups to disk when they do not
+       fit into o.MemoryLimit kilobytes */
+
+    var (
+        sp     *spill
+        groups []*group
+        size   uint
+        n      int
+    )
+
+    limit := o.MemoryLimit * 1024
+    seen := make(map[string]*group)
+    scanner := newLineScanner(ctx, reader, o)
+    defer scanner.count(stats)
+
+    for scanner.Scan() {
+        n += 1
+        line := o.Mapper(scanner.Text())
+        key := o.Cutter(line)
+        g := &group{line: line, count: 1, first: n, last: n}
+
+        if sp != nil {
+            if err = sp.add(key, g); err != nil {
+                return
+            }
+            continue
+        }
+
+        if prev, ok := seen[key]; ok {
+            size -= uint(len(prev.line))
+            prev.merge(g, o.KeepLast)
+            size += uint(len(prev.line))
+            continue
+        }
+
+        seen[key] = g
+        groups = append(groups, g)
+        size += uint(len(line)) + groupOverhead
+
+        if size > limit {
+            if sp, err = newSpill(limit); err != nil {
+                return
+            }
+            defer sp.close()
+
+            for _, g := range groups {
+                if err = sp.add(o.Cutter(g.line), g); err != nil {
+                    return
+                }
+            }
+            seen, groups = nil, nil
+        }
+    }
+
+    if err = ctx.Err(); err != nil {
+        return
+    }
+
+    if sp != nil {
+        err = sp.emit(ctx, writer, o, m, stats)
+    } else {
+        stats.Groups = int64(len(groups))
+        for _, g := range groups {
+            if m.accept(g.count) {
+                if err = m.emit(writer, o, g.line, g.count); err != nil {
+                    return
+                }
+            }
+        }
+    }
+
+    if err == nil {
+        err = scanner.Err()
+    }
+    return
+}
@@ -0,0 +1,74 @@
+package dedup
+
+import (
+    "bytes"
+    "context"
+    "fmt"
+    "io"
+    "strings"
+    "testing"
+)
+
+func TestSpill(t *testing.T) {
+    var input strings.Builder
+    for i := 0; i < 20000; i++ {
+        fmt.Fprintf(&input, "line %d\n", i*7%5000)
+    }
+
+    for name, process := range map[string]ProcessFunc{
+        "deduplicate": Deduplicate,
+        "unique":      Unique,
+        "duplicates":  Duplicates,
+        "count":       Count,
+    } {
+        for _, last := range []bool{false, true} {
+            var expected, got bytes.Buffer
+
+            opts := Options{Global: true, KeepLast: last}
+            if _, err := process(context.Background(), strings.NewReader(input.String()), &expected, &opts); err != nil {
+                t.Fatal(err)
+            }
+            opts.MemoryLimit = 4
+            stats, err := process(context.Background(), strings.NewReader(input.String()), &got, &opts)
+            if err != nil {
+                t.Fatal(err)
+            }
+
+            if got.String() != expected.String() || stats.Groups != 5000 {
+                t.Errorf("%s, last %v: the spilled groups are different, %d groups", name, last, stats.Groups)
+            }
+        }
+    }
+}
+
+func TestSpillSplit(t *testing.T) {
+    o := (&Options{Global: true}).normalize()
+
+    sp, err := newSpill(4096)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer sp.close()
+
+    for i := 0; i < 20000; i++ {
+        line := fmt.Sprintf("line %d", i%5000)
+        if err := sp.add(line, &group{line: line, count: 1, first: i + 1, last: i + 1}); err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    var stats Stats
+    if err := sp.emit(context.Background(), io.Discard, o, modeCount, &stats); err != nil {
+        t.Fatal(err)
+    }
+
+    // the buckets larger than the limit are split
+    if len(sp.buckets) <= spillBuckets || stats.Groups != 5000 {
+        t.Errorf("%d buckets, %d groups", len(sp.buckets), stats.Groups)
+    }
+    for _, b := range sp.buckets {
+        if b != nil && b.size > int64(sp.limit) {
+            t.Errorf("a bucket of %d bytes is not split", b.size)
+        }
+    }
+}
@@ -0,0 +1,106 @@
+package dedup
+
+type text interface {
+    ~string | ~[]byte
+}
+
+// isWord reports whether line[i] is a word character of regexp \b
+func isWord[T text](line T, i int) bool {
+    if i < 0 || i >= len(line) {
+        return false
+    }
+    c := line[i]
+    return c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// isSpace reports whether c is a space of regexp \s
+func isSpace(c byte) bool {
+    return c == ' ' || c == '\t' || c == '\n' || c == '\f' || c == '\r'
+}
+
+// boundary reports whether there is a word boundary \b before line[i]
+func boundary[T text](line T, i int) bool {
+    return isWord(line, i-1) != isWord(line, i)
+}
+
+// fieldStart returns the start of the field n (from 0) or -1 if there
+// are fewer fields. The fields are the matches of regexp `\b(\S+)\b`.
+func fieldStart[T text](line T, n uint) int {
+    i := 0
+
+    for i < len(line) {
+        if isSpace(line[i]) || !boundary(line, i) {
+            i += 1
+            continue
+        }
+
+        // the longest run of non-space characters that ends at a boundary
+        j := i
+        for j < len(line) && !isSpace(line[j]) {
+            j += 1
+        }
+        for j > i && !boundary(line, j) {
+            j -= 1
+        }
+
+        if j == i {
+            i += 1
+            continue
+        }
+
+        if n == 0 {
+            return i
+        }
+        n -= 1
+        i = j
+    }
+
+    return -1
+}
+
+// Substring returns the range of the line that is compared after skipping
+// numFields fields and skipChars characters and taking takeChars characters
+func Substring(
+    line string,
+    numFields, skipChars, takeChars uint) (idx [2]uint) {
+
+    return substring(line, numFields, skipChars, takeChars)
+}
+
+func substring[T text](
+    line T,
+    numFields, skipChars, takeChars uint) (idx [2]uint) {
+    var (
+        start uint
+        end   uint = uint(len(line))
+    )
+
+    if numFields > 0 {
+        if i := fieldStart(line, numFields); i >= 0 {
+            start = uint(i)
+        } else {
+            start = end
+        }
+    }
+
+    if skipChars > 0 {
+        ll := uint(len(line[start:]))
+        if skipChars < ll {
+            start += skipChars
+        } else {
+            start = end
+        }
+    }
+
+    if takeChars > 0 {
+        ll := uint(len(line[start:]))
+        if takeChars < ll {
+            end = start + takeChars
+        }
+    }
+
+    idx[0] = start
+    idx[1] = end
+
+    return
+}
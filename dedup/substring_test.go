@@ -0,0 +1,32 @@
+package dedup
+
+import (
+    "math/rand"
+    "regexp"
+    "testing"
+)
+
+var reWord = regexp.MustCompile(`\b(\S+)\b`)
+
+func TestFieldStart(t *testing.T) {
+    alphabet := []byte("ab1_ -.,\t\v\xc3\xa9\xff")
+    r := rand.New(rand.NewSource(1))
+
+    for k := 0; k < 20000; k++ {
+        line := make([]byte, r.Intn(12))
+        for i := range line {
+            line[i] = alphabet[r.Intn(len(alphabet))]
+        }
+
+        fields := reWord.FindAllIndex(line, -1)
+        for n := 0; n <= len(fields); n++ {
+            expected := -1
+            if n < len(fields) {
+                expected = fields[n][0]
+            }
+            if got := fieldStart(line, uint(n)); got != expected {
+                t.Fatalf("fieldStart(%q, %d) = %d, expected %d", line, n, got, expected)
+            }
+        }
+    }
+}
@@ -0,0 +1,53 @@
+package dedup
+
+import (
+    "context"
+    "fmt"
+    "io"
+
+    "uniq/sketch"
+)
+
+// the number of counters for Top when o.TopCapacity is not set
+const topCapacityFactor = 10
+
+// Top prints the opts.Top most frequent lines of unsorted input in bounded
+// memory. Each count may exceed the real number by the printed error.
+func Top(
+    ctx context.Context,
+    reader io.Reader,
+    writer io.Writer,
+    opts *Options) (stats Stats, err error) {
+
+    o := opts.normalize()
+    if reader, err = o.shard(reader); err != nil {
+        return
+    }
+
+    capacity := o.TopCapacity
+    if capacity < o.Top {
+        capacity = o.Top * topCapacityFactor
+    }
+    counters := sketch.NewSpaceSaving(int(capacity))
+
+    scanner := newLineScanner(ctx, reader, o)
+
+    for scanner.Scan() {
+        line := o.Mapper(scanner.Text())
+        counters.Add(o.Cutter(line), line)
+    }
+    scanner.count(&stats)
+
+    if err = ctx.Err(); err != nil {
+        return
+    }
+
+    for _, c := range counters.Top(int(o.Top)) {
+        if err = o.Fprintln(writer, fmt.Sprintf(o.FormatTop, c.Count, c.Error, c.Item)); err != nil {
+            return
+        }
+    }
+
+    err = scanner.Err()
+    return
+}
@@ -0,0 +1,176 @@
+package dedup
+
+import (
+    "fmt"
+    "regexp"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+)
+
+// Transformer changes the key of a line before it is compared
+type Transformer interface {
+    Transform(key string) string
+}
+
+// TransformerFunc makes a Transformer of a function
+type TransformerFunc func(string) string
+
+func (f TransformerFunc) Transform(key string) string {
+    return f(key)
+}
+
+// Chain applies the transformers in order
+type Chain []Transformer
+
+func (c Chain) Transform(key string) string {
+    for _, t := range c {
+        key = t.Transform(key)
+    }
+    return key
+}
+
+// TransformerFactory makes a transformer from the argument of its name
+// in a chain spec, which is empty if there is no argument
+type TransformerFactory func(arg string) (Transformer, error)
+
+var (
+    registryMu sync.RWMutex
+    registry   = make(map[string]TransformerFactory)
+)
+
+// Register makes a transformer available by name in NewChain.
+// It panics if the name is registered twice, like database/sql.Register.
+func Register(name string, factory TransformerFactory) {
+    registryMu.Lock()
+    defer registryMu.Unlock()
+
+    if factory == nil {
+        panic("dedup: Register factory is nil")
+    }
+    if _, dup := registry[name]; dup {
+        panic("dedup: Register called twice for transformer " + name)
+    }
+    registry[name] = factory
+}
+
+// Transformers returns the sorted names of the registered transformers
+func Transformers() []string {
+    registryMu.RLock()
+    defer registryMu.RUnlock()
+
+    names := make([]string, 0, len(registry))
+    for name := range registry {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    return names
+}
+
+// specItem matches the start of an item of a spec: a name followed
+// by its argument, the next item or the end
+var specItem = regexp.MustCompile(`^\s*[\w-]+\s*(:|,|$)`)
+
+// splitSpec splits the spec by the commas followed by an item, so
+// an argument can have commas like "mask:[0-9]{1,3}"
+func splitSpec(spec string) (items []string) {
+    start := 0
+    for i := 0; i < len(spec); i++ {
+        if spec[i] == ',' && specItem.MatchString(spec[i+1:]) {
+            items = append(items, spec[start:i])
+            start = i + 1
+        }
+    }
+    return append(items, spec[start:])
+}
+
+// NewChain makes a chain of registered transformers from a spec like
+// "normalize,lower,mask:[0-9]+". Every comma separated item is a name
+// optionally followed by a colon and the argument of the transformer.
+// A comma that is not followed by a name belongs to the argument.
+func NewChain(spec string) (chain Chain, err error) {
+    registryMu.RLock()
+    defer registryMu.RUnlock()
+
+    for _, item := range splitSpec(spec) {
+        name, arg := item, ""
+        if i := strings.IndexByte(item, ':'); i >= 0 {
+            name, arg = item[:i], item[i+1:]
+        }
+        name = strings.TrimSpace(name)
+
+        factory, ok := registry[name]
+        if !ok {
+            return nil, fmt.Errorf("unknown transformer %q", name)
+        }
+
+        t, err := factory(arg)
+        if err != nil {
+            return nil, fmt.Errorf("transformer %q: %w", name, err)
+        }
+        chain = append(chain, t)
+    }
+    return
+}
+
+// Cut returns the part of the key that remains after skipping numFields
+// fields and skipChars characters and taking takeChars characters
+func Cut(numFields, skipChars, takeChars uint) Transformer {
+    return TransformerFunc(func(key string) string {
+        idx := Substring(key, numFields, skipChars, takeChars)
+        // to avoid unnecessary attempts to take a slice
+        if idx[0] != 0 || idx[1] != uint(len(key)) {
+            key = key[idx[0]:idx[1]]
+        }
+        return key
+    })
+}
+
+// the character that replaces the matches of mask
+const maskChar = "#"
+
+func noArg(t TransformerFunc) TransformerFactory {
+    return func(arg string) (Transformer, error) {
+        if arg != "" {
+            return nil, fmt.Errorf("unexpected argument %q", arg)
+        }
+        return t, nil
+    }
+}
+
+func cutArg(cut func(n uint) Transformer) TransformerFactory {
+    return func(arg string) (Transformer, error) {
+        n, err := strconv.ParseUint(arg, 10, 0)
+        if err != nil {
+            return nil, err
+        }
+        return cut(uint(n)), nil
+    }
+}
+
+func init() {
+    Register("lower", noArg(strings.ToLower))
+    Register("upper", noArg(strings.ToUpper))
+    Register("trim", noArg(strings.TrimSpace))
+    // collapses the runs of spaces and trims the key
+    Register("normalize", noArg(func(key string) string {
+        return strings.Join(strings.Fields(key), " ")
+    }))
+    // replaces the matches of a regexp, numbers by default
+    Register("mask", func(arg string) (Transformer, error) {
+        if arg == "" {
+            arg = "[0-9]+"
+        }
+        re, err := regexp.Compile(arg)
+        if err != nil {
+            return nil, err
+        }
+        return TransformerFunc(func(key string) string {
+            return re.ReplaceAllLiteralString(key, maskChar)
+        }), nil
+    })
+    Register("fields", cutArg(func(n uint) Transformer { return Cut(n, 0, 0) }))
+    Register("skip", cutArg(func(n uint) Transformer { return Cut(0, n, 0) }))
+    Register("take", cutArg(func(n uint) Transformer { return Cut(0, 0, n) }))
+}
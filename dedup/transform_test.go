@@ -0,0 +1,90 @@
+package dedup
+
+import (
+    "context"
+    "os"
+    "strings"
+    "testing"
+)
+
+func ExampleNewChain() {
+    key, err := NewChain("normalize,lower,mask")
+    if err != nil {
+        panic(err)
+    }
+
+    opts := DefaultOptions()
+    opts.Key = key
+
+    input := "Request 1 done\nrequest   2 done\nrequest 3 failed"
+    Deduplicate(context.Background(), strings.NewReader(input), os.Stdout, &opts)
+    // Output:
+    // Request 1 done
+    // request 3 failed
+}
+
+func TestNewChain(t *testing.T) {
+
+    testCases := []struct {
+        spec     string
+        key      string
+        expected string
+    }{
+        {"lower", "AbC", "abc"},
+        {"upper", "AbC", "ABC"},
+        {"trim", "  a b  ", "a b"},
+        {"normalize", "  a \t b  ", "a b"},
+        {"mask", "id 12 at 3", "id # at #"},
+        {"mask:[a-z]+", "id 12", "# 12"},
+        {"fields:1", "a b c", "b c"},
+        {"skip:2,take:2", "abcdef", "cd"},
+        {" lower , mask:\\d", "A1", "a#"},
+        {"mask:[0-9]{1,3},lower", "AB1234", "ab##"},
+        {"mask:x{2,}, upper", "axxxb", "A#B"},
+    }
+
+    for _, tc := range testCases {
+        chain, err := NewChain(tc.spec)
+        if err != nil {
+            t.Errorf("NewChain(%q): %v", tc.spec, err)
+            continue
+        }
+        if got := chain.Transform(tc.key); got != tc.expected {
+            t.Errorf("NewChain(%q).Transform(%q) = %q, expected %q",
+                tc.spec, tc.key, got, tc.expected)
+        }
+    }
+
+    for _, spec := range []string{"", "unknown", "lower:1", "mask:(", "take:x", "mask:[0-9]+,unknown", "lower,,upper"} {
+        if _, err := NewChain(spec); err == nil {
+            t.Errorf("NewChain(%q) returned no error", spec)
+        }
+    }
+}
+
+func TestRegister(t *testing.T) {
+    Register("test-reverse", func(arg string) (Transformer, error) {
+        return TransformerFunc(func(key string) string {
+            r := []rune(key)
+            for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+                r[i], r[j] = r[j], r[i]
+            }
+            return string(r)
+        }), nil
+    })
+
+    chain, err := NewChain("test-reverse,take:2")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if got := chain.Transform("abc"); got != "cb" {
+        t.Errorf("got %q", got)
+    }
+
+    defer func() {
+        if recover() == nil {
+            t.Errorf("Register of a duplicate name did not panic")
+        }
+    }()
+    Register("lower", noArg(strings.ToLower))
+}
@@ -0,0 +1,84 @@
+package dedup
+
+import (
+    "context"
+    "io"
+    "math"
+)
+
+// canTwoPass reports whether Unique and Duplicates can read the input twice
+// instead of keeping the lines in memory
+func canTwoPass(reader io.Reader, o *Options, m mode) bool {
+    if m != modeUnique && m != modeDuplicates || o.KeepLast || o.Verify || o.MemoryLimit > 0 {
+        return false
+    }
+    _, _, ok := seekableInput(reader)
+    return ok
+}
+
+func twoPassLines(
+    ctx context.Context,
+    reader io.Reader,
+    writer io.Writer,
+    o *Options,
+    m mode,
+    stats *Stats) (err error) {
+    /* Global Unique and Duplicates for seekable input: the first pass counts the
+       fingerprints of the keys, the second one prints the lines in the
+       original order */
+
+    input, base, _ := seekableInput(reader)
+
+    bits := o.Fingerprint
+    if bits != 64 {
+        bits = 128
+    }
+
+    counts := make(map[fingerprint]uint32)
+    scanner := newLineScanner(ctx, reader, o)
+    var fs FingerprintStats
+
+    for scanner.Scan() {
+        key := o.Cutter(o.Mapper(scanner.Text()))
+        fp := newFingerprint(key, bits)
+        if counts[fp] == 0 {
+            fs.KeyBytes += int64(len(key))
+        }
+        if counts[fp] < math.MaxUint32 {
+            counts[fp] += 1
+        }
+    }
+    // only the first pass is counted in the statistics
+    scanner.count(stats)
+    stats.Groups = int64(len(counts))
+    if o.Fingerprint > 0 {
+        // the lines are read again, like with Fingerprint
+        fs.FingerprintBytes = stats.Groups * int64(bits/8)
+        stats.Fingerprint = &fs
+    }
+
+    if err = scanner.Err(); err != nil {
+        return
+    }
+
+    scanner = newLineScanner(ctx, section(input, base, math.MaxInt64-base), o)
+
+    for scanner.Scan() {
+        line := o.Mapper(scanner.Text())
+        fp := newFingerprint(o.Cutter(line), bits)
+
+        switch cnt := counts[fp]; {
+        case m == modeUnique && cnt == 1:
+            err = o.Fprintln(writer, line)
+        case m == modeDuplicates && cnt > 1:
+            err = o.Fprintln(writer, line)
+            // only the first line of the group is printed
+            counts[fp] = 0
+        }
+        if err != nil {
+            return
+        }
+    }
+
+    return scanner.Err()
+}
@@ -0,0 +1,75 @@
+package dedup
+
+import (
+    "bytes"
+    "context"
+    "io"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+func TestTwoPass(t *testing.T) {
+    input := "x skipped\n1 aaa\n2 AAA\n3 bbb\n1 aaa\n4 ccc\n5 Bbb\n2 aaa\n6 aaa\n7 ddd\n8 CCC\n4 ccc\n9 eee"
+
+    path := filepath.Join(t.TempDir(), "input.txt")
+    if err := os.WriteFile(path, []byte(input), 0644); err != nil {
+        t.Fatal(err)
+    }
+
+    for name, process := range map[string]ProcessFunc{"Unique": Unique, "Duplicates": Duplicates} {
+        m := modeUnique
+        if name == "Duplicates" {
+            m = modeDuplicates
+        }
+
+        for _, opts := range []Options{
+            {Global: true},
+            {Global: true, IgnoreCase: true},
+            {Global: true, NumFields: 1},
+            {Global: true, IgnoreCase: true, NumFields: 1},
+            {Global: true, IgnoreCase: true, Fingerprint: 64},
+        } {
+            opts := opts
+
+            f, err := os.Open(path)
+            if err != nil {
+                t.Fatal(err)
+            }
+            // the lines are counted from the current position
+            if _, err := f.Seek(int64(strings.Index(input, "\n")+1), io.SeekStart); err != nil {
+                t.Fatal(err)
+            }
+            pipe := io.MultiReader(strings.NewReader(input[strings.Index(input, "\n")+1:]))
+
+            if !canTwoPass(f, &opts, m) || canTwoPass(pipe, &opts, m) {
+                t.Fatalf("%s(%+v): the file is not read twice", name, opts)
+            }
+
+            var twoPass, inMemory bytes.Buffer
+            stats, err := process(context.Background(), f, &twoPass, &opts)
+            f.Close()
+            if err != nil {
+                t.Fatal(err)
+            }
+            expected, err := process(context.Background(), pipe, &inMemory, &opts)
+            if err != nil {
+                t.Fatal(err)
+            }
+
+            if twoPass.String() != inMemory.String() {
+                t.Errorf("%s(%+v): got %q, expected %q", name, opts, twoPass.String(), inMemory.String())
+            }
+            if stats.Lines != expected.Lines || stats.Groups != expected.Groups {
+                t.Errorf("%s(%+v): got %+v, expected %+v", name, opts, stats, expected)
+            }
+            if opts.Fingerprint > 0 {
+                fp, want := stats.Fingerprint, expected.Fingerprint
+                if fp == nil || fp.KeyBytes != want.KeyBytes || fp.FingerprintBytes != want.FingerprintBytes {
+                    t.Errorf("%s(%+v): got %+v, expected %+v", name, opts, fp, want)
+                }
+            }
+        }
+    }
+}
@@ -0,0 +1,166 @@
+package dedup
+
+import (
+    "container/list"
+    "context"
+    "io"
+)
+
+type recentGroup struct {
+    key   string
+    line  string
+    count int
+    // number of the last line of the group
+    last int
+}
+
+// recent keeps the open groups from the least recently seen one
+type recent struct {
+    order *list.List
+    index map[string]*list.Element
+    // approximate memory taken by the groups
+    size uint
+    stats CacheStats
+}
+
+func newRecent() *recent {
+    return &recent{
+        order: list.New(),
+        index: make(map[string]*list.Element),
+    }
+}
+
+// touch counts the line n in the group of key and makes the group
+// the most recent one
+func (r *recent) touch(key, line string, n int) (g *recentGroup, seen bool) {
+    if e, ok := r.index[key]; ok {
+        g = e.Value.(*recentGroup)
+        g.count += 1
+        g.last = n
+        r.order.MoveToBack(e)
+        r.stats.Hits += 1
+        return g, true
+    }
+
+    g = &recentGroup{key: key, line: line, count: 1, last: n}
+    r.index[key] = r.order.PushBack(g)
+    r.size += g.size()
+    r.stats.Misses += 1
+    return g, false
+}
+
+func (r *recent) oldest() *recentGroup {
+    if e := r.order.Front(); e != nil {
+        return e.Value.(*recentGroup)
+    }
+    return nil
+}
+
+func (r *recent) remove(g *recentGroup) {
+    r.order.Remove(r.index[g.key])
+    delete(r.index, g.key)
+    r.size -= g.size()
+}
+
+func (g *recentGroup) size() uint {
+    return uint(len(g.key)+len(g.line)) + groupOverhead
+}
+
+func recentLines(
+    ctx context.Context,
+    reader io.Reader,
+    writer io.Writer,
+    o *Options,
+    m mode,
+    stats *Stats,
+    r *recent,
+    expired func(r *recent, g *recentGroup, n int) bool) (err error) {
+    /* A line is a duplicate of an open group with the same key. Groups
+       are closed by the expired policy, so Unique and Count print them
+       in the order they are closed. */
+
+    closeGroup := func(g *recentGroup) error {
+        r.remove(g)
+        if m == modeUnique && g.count == 1 || m == modeCount {
+            return m.emit(writer, o, g.line, g.count)
+        }
+        return nil
+    }
+
+    scanner := newLineScanner(ctx, reader, o)
+    defer func() {
+        scanner.count(stats)
+        stats.Groups = r.stats.Misses
+    }()
+    n := 0
+
+    for scanner.Scan() {
+        n += 1
+        line := o.Mapper(scanner.Text())
+        g, seen := r.touch(o.Cutter(line), line, n)
+
+        if !seen && m == modeDeduplicate || m == modeDuplicates && g.count == 2 {
+            if err = o.Fprintln(writer, g.line); err != nil {
+                return
+            }
+        }
+
+        for g := r.oldest(); g != nil && expired(r, g, n); g = r.oldest() {
+            if err = closeGroup(g); err != nil {
+                return
+            }
+            r.stats.Evictions += 1
+        }
+    }
+
+    if err = ctx.Err(); err != nil {
+        return
+    }
+
+    for g := r.oldest(); g != nil; g = r.oldest() {
+        if err = closeGroup(g); err != nil {
+            return
+        }
+    }
+
+    return scanner.Err()
+}
+
+func windowLines(
+    ctx context.Context,
+    reader io.Reader,
+    writer io.Writer,
+    o *Options,
+    m mode,
+    stats *Stats) error {
+    /* A line is a duplicate if its key is among the last o.Window lines */
+
+    window := int(o.Window)
+    return recentLines(ctx, reader, writer, o, m, stats, newRecent(),
+        func(r *recent, g *recentGroup, n int) bool {
+            // the next line can not reach the group
+            return g.last <= n-window
+        })
+}
+
+func cacheLines(
+    ctx context.Context,
+    reader io.Reader,
+    writer io.Writer,
+    o *Options,
+    m mode,
+    stats *Stats) error {
+    /* A line is a duplicate if its key is in the LRU cache of at most
+       o.CacheSize keys taking at most o.CacheBytes bytes */
+
+    r := newRecent()
+    defer func() {
+        stats.Cache = &r.stats
+    }()
+
+    return recentLines(ctx, reader, writer, o, m, stats, r,
+        func(r *recent, g *recentGroup, n int) bool {
+            return o.CacheSize > 0 && uint(r.order.Len()) > o.CacheSize ||
+                o.CacheBytes > 0 && r.size > o.CacheBytes
+        })
+}
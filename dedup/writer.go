@@ -0,0 +1,155 @@
+package dedup
+
+import (
+    "bytes"
+    "fmt"
+    "io"
+    "sync"
+    "time"
+)
+
+// Writer passes the lines written to it to another writer and suppresses
+// the lines with the same key as the previous one, like Deduplicate does.
+// The suppressed lines are replaced by a summary formatted with
+// FormatRepeated, "last message repeated N times" by default, which is
+// written before the next different line, by Flush, or after the timeout.
+//
+// The lines are written unchanged, Mapper and Cutter only make the keys.
+// Writer is safe for concurrent use, so it can be the output of a log.Logger.
+type Writer struct {
+    mu      sync.Mutex
+    w       io.Writer
+    o       *Options
+    timeout time.Duration
+    timer   *time.Timer
+
+    // incomplete last line
+    buf  []byte
+    key  string
+    seen bool
+    // number of suppressed lines since the last summary
+    repeated int
+    // the first error of writing, it is returned by all later calls
+    err error
+}
+
+// NewWriter returns a Writer to w. If timeout is positive, the summary
+// is written at most timeout after the first suppressed line.
+func NewWriter(w io.Writer, opts *Options, timeout time.Duration) *Writer {
+    return &Writer{w: w, o: opts.normalize(), timeout: timeout}
+}
+
+func (w *Writer) Write(p []byte) (n int, err error) {
+    w.mu.Lock()
+    defer w.mu.Unlock()
+
+    if w.err != nil {
+        return 0, w.err
+    }
+
+    w.buf = append(w.buf, p...)
+    for {
+        i := bytes.IndexByte(w.buf, '\n')
+        if i < 0 {
+            break
+        }
+        line := string(bytes.TrimSuffix(w.buf[:i], []byte{'\r'}))
+        w.buf = w.buf[i+1:]
+
+        if err = w.writeLine(line); err != nil {
+            return 0, err
+        }
+    }
+
+    // do not keep a large buffer behind a short incomplete line
+    if len(w.buf) == 0 {
+        w.buf = nil
+    }
+    return len(p), nil
+}
+
+func (w *Writer) writeLine(line string) (err error) {
+    key := w.o.Cutter(w.o.Mapper(line))
+
+    if w.seen && key == w.key {
+        w.repeated += 1
+        if w.repeated == 1 && w.timeout > 0 {
+            w.startTimer()
+        }
+        return
+    }
+
+    if err = w.summary(); err != nil {
+        return
+    }
+    w.key, w.seen = key, true
+
+    if err = w.o.Fprintln(w.w, line); err != nil {
+        w.err = err
+    }
+    return
+}
+
+// summary writes the number of the suppressed lines
+func (w *Writer) summary() (err error) {
+    if w.timer != nil {
+        w.timer.Stop()
+        w.timer = nil
+    }
+    if w.repeated == 0 {
+        return
+    }
+
+    err = w.o.Fprintln(w.w, fmt.Sprintf(w.o.FormatRepeated, w.repeated))
+    w.repeated = 0
+    if err != nil {
+        w.err = err
+    }
+    return
+}
+
+func (w *Writer) startTimer() {
+    var timer *time.Timer
+    timer = time.AfterFunc(w.timeout, func() {
+        w.mu.Lock()
+        defer w.mu.Unlock()
+
+        // the summary was already written
+        if w.timer != timer || w.err != nil {
+            return
+        }
+        w.summary()
+    })
+    w.timer = timer
+}
+
+// Flush writes the summary of the suppressed lines if there are any.
+// Later lines with the same key are suppressed again.
+func (w *Writer) Flush() error {
+    w.mu.Lock()
+    defer w.mu.Unlock()
+
+    if w.err != nil {
+        return w.err
+    }
+    return w.summary()
+}
+
+// Close writes the incomplete last line and the summary. It does not
+// close the underlying writer.
+func (w *Writer) Close() (err error) {
+    w.mu.Lock()
+    defer w.mu.Unlock()
+
+    if w.err != nil {
+        return w.err
+    }
+    if len(w.buf) > 0 {
+        line := string(w.buf)
+        w.buf = nil
+        if err = w.writeLine(line); err != nil {
+            return
+        }
+    }
+    return w.summary()
+}
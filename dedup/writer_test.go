@@ -0,0 +1,99 @@
+package dedup
+
+import (
+    "bytes"
+    "errors"
+    "log"
+    "os"
+    "strings"
+    "sync"
+    "testing"
+    "time"
+)
+
+func ExampleNewWriter() {
+    opts := DefaultOptions()
+    opts.Mapper = strings.ToLower
+
+    w := NewWriter(os.Stdout, &opts, 0)
+    defer w.Close()
+
+    logger := log.New(w, "", 0)
+    logger.Println("connection refused")
+    logger.Println("Connection refused")
+    logger.Println("connection refused")
+    logger.Println("connected")
+    logger.Println("connected")
+    // Output:
+    // connection refused
+    // last message repeated 2 times
+    // connected
+    // last message repeated 1 times
+}
+
+// syncBuffer is a bytes.Buffer safe to write from the timer goroutine
+type syncBuffer struct {
+    mu  sync.Mutex
+    buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    return b.buf.String()
+}
+
+func TestWriterTimeout(t *testing.T) {
+    var out syncBuffer
+
+    w := NewWriter(&out, nil, 10*time.Millisecond)
+    w.Write([]byte("a\na\na"))
+    w.Write([]byte("\n"))
+
+    deadline := time.Now().Add(5 * time.Second)
+    for out.String() != "a\nlast message repeated 2 times\n" {
+        if time.Now().After(deadline) {
+            t.Fatalf("no summary after the timeout, got %q", out.String())
+        }
+        time.Sleep(time.Millisecond)
+    }
+
+    // the repeats after the summary are still suppressed
+    w.Write([]byte("a\nb"))
+    if err := w.Close(); err != nil {
+        t.Fatal(err)
+    }
+    if got := out.String(); got != "a\nlast message repeated 2 times\nlast message repeated 1 times\nb\n" {
+        t.Errorf("got %q", got)
+    }
+}
+
+func TestWriterKey(t *testing.T) {
+    var out bytes.Buffer
+
+    opts := Options{Key: Cut(1, 0, 0), FormatRepeated: "(%d more)"}
+    w := NewWriter(&out, &opts, 0)
+    w.Write([]byte("10:00 disk full\n10:01 disk full\n10:02 disk ok\n"))
+    w.Flush()
+
+    if got := out.String(); got != "10:00 disk full\n(1 more)\n10:02 disk ok\n" {
+        t.Errorf("got %q", got)
+    }
+}
+
+func TestWriterError(t *testing.T) {
+    w := NewWriter(failingWriter{}, nil, 0)
+
+    if _, err := w.Write([]byte("a\n")); !errors.Is(err, errWrite) {
+        t.Errorf("Write returned %v", err)
+    }
+    if err := w.Close(); !errors.Is(err, errWrite) {
+        t.Errorf("Close returned %v", err)
+    }
+}
@@ -0,0 +1,168 @@
+package generic
+
+import (
+    "context"
+)
+
+// Mode selects the groups that are returned
+type Mode uint8
+
+const (
+    // ModeAll returns every group, like uniq
+    ModeAll Mode = iota
+    // ModeUnique returns the groups of one value, like uniq -u
+    ModeUnique
+    // ModeDuplicates returns the groups of several values, like uniq -d
+    ModeDuplicates
+)
+
+// Accept reports whether a group of count values is returned in this mode
+func (m Mode) Accept(count int) bool {
+    switch m {
+    case ModeUnique:
+        return count == 1
+    case ModeDuplicates:
+        return count > 1
+    }
+    return true
+}
+
+// Groups returns the groups of the values that are accepted by the mode
+func Groups[T any, K comparable](
+    items []T,
+    key func(T) K,
+    opts *Options,
+    m Mode) (groups []Group[T, K]) {
+
+    g := NewGrouper(key, opts)
+
+    for _, item := range items {
+        if closed, ok := g.Add(item); ok && m.Accept(closed.Count) {
+            groups = append(groups, closed)
+        }
+    }
+    for _, closed := range g.Close() {
+        if m.Accept(closed.Count) {
+            groups = append(groups, closed)
+        }
+    }
+    return
+}
+
+func representatives[T any, K comparable](groups []Group[T, K]) []T {
+    items := make([]T, len(groups))
+    for i, g := range groups {
+        items[i] = g.Item
+    }
+    return items
+}
+
+// Dedup returns one value of every group of equal values
+func Dedup[T any, K comparable](items []T, key func(T) K, opts *Options) []T {
+    return representatives(Groups(items, key, opts, ModeAll))
+}
+
+// Unique returns the values that are not repeated
+func Unique[T any, K comparable](items []T, key func(T) K, opts *Options) []T {
+    return representatives(Groups(items, key, opts, ModeUnique))
+}
+
+// Duplicates returns one value of every group of repeated values
+func Duplicates[T any, K comparable](items []T, key func(T) K, opts *Options) []T {
+    return representatives(Groups(items, key, opts, ModeDuplicates))
+}
+
+// Count returns every group of equal values with the number of the values
+func Count[T any, K comparable](items []T, key func(T) K, opts *Options) []Group[T, K] {
+    return Groups(items, key, opts, ModeAll)
+}
+
+// GroupsChan sends the groups of the values received from in that are
+// accepted by the mode. Adjacent groups are sent as soon as they are closed,
+// global ones after in is closed. The result is closed after in is closed
+// or ctx is done.
+func GroupsChan[T any, K comparable](
+    ctx context.Context,
+    in <-chan T,
+    key func(T) K,
+    opts *Options,
+    m Mode) <-chan Group[T, K] {
+
+    out := make(chan Group[T, K])
+
+    go func() {
+        defer close(out)
+
+        send := func(group Group[T, K]) bool {
+            if !m.Accept(group.Count) {
+                return true
+            }
+            select {
+            case out <- group:
+                return true
+            case <-ctx.Done():
+                return false
+            }
+        }
+
+        g := NewGrouper(key, opts)
+
+        for {
+            select {
+            case item, ok := <-in:
+                if !ok {
+                    for _, closed := range g.Close() {
+                        if !send(closed) {
+                            return
+                        }
+                    }
+                    return
+                }
+                if closed, ok := g.Add(item); ok && !send(closed) {
+                    return
+                }
+            case <-ctx.Done():
+                return
+            }
+        }
+    }()
+
+    return out
+}
+
+func representativesChan[T any, K comparable](ctx context.Context, groups <-chan Group[T, K]) <-chan T {
+    out := make(chan T)
+
+    go func() {
+        defer close(out)
+        for g := range groups {
+            select {
+            case out <- g.Item:
+            case <-ctx.Done():
+                return
+            }
+        }
+    }()
+
+    return out
+}
+
+// DedupChan is Dedup for a channel, see GroupsChan
+func DedupChan[T any, K comparable](ctx context.Context, in <-chan T, key func(T) K, opts *Options) <-chan T {
+    return representativesChan(ctx, GroupsChan(ctx, in, key, opts, ModeAll))
+}
+
+// UniqueChan is Unique for a channel, see GroupsChan
+func UniqueChan[T any, K comparable](ctx context.Context, in <-chan T, key func(T) K, opts *Options) <-chan T {
+    return representativesChan(ctx, GroupsChan(ctx, in, key, opts, ModeUnique))
+}
+
+// DuplicatesChan is Duplicates for a channel, see GroupsChan
+func DuplicatesChan[T any, K comparable](ctx context.Context, in <-chan T, key func(T) K, opts *Options) <-chan T {
+    return representativesChan(ctx, GroupsChan(ctx, in, key, opts, ModeDuplicates))
+}
+
+// CountChan is Count for a channel, see GroupsChan
+func CountChan[T any, K comparable](ctx context.Context, in <-chan T, key func(T) K, opts *Options) <-chan Group[T, K] {
+    return GroupsChan(ctx, in, key, opts, ModeAll)
+}
@@ -0,0 +1,119 @@
+package generic
+
+import (
+    "context"
+    "fmt"
+    "reflect"
+    "strings"
+    "testing"
+)
+
+type event struct {
+    Host string
+    Code int
+}
+
+func ExampleCount() {
+    events := []event{
+        {"a", 500}, {"b", 404}, {"a", 503}, {"c", 500}, {"a", 500},
+    }
+
+    host := func(e event) string { return e.Host }
+
+    for _, g := range Count(events, host, &Options{Global: true}) {
+        fmt.Println(g.Key, g.Count, g.Item.Code)
+    }
+    // Output:
+    // a 3 500
+    // b 1 404
+    // c 1 500
+}
+
+func TestSlices(t *testing.T) {
+    items := []string{"b", "B", "a", "b", "b", "c"}
+    key := strings.ToLower
+
+    testCases := []struct {
+        name     string
+        fn       func([]string, func(string) string, *Options) []string
+        opts     *Options
+        expected []string
+    }{
+        {"Dedup", Dedup[string, string], nil, []string{"b", "a", "b", "c"}},
+        {"Dedup global", Dedup[string, string], &Options{Global: true}, []string{"b", "a", "c"}},
+        {"Dedup global last", Dedup[string, string], &Options{Global: true, KeepLast: true}, []string{"b", "a", "c"}},
+        {"Unique", Unique[string, string], nil, []string{"a", "c"}},
+        {"Unique global", Unique[string, string], &Options{Global: true}, []string{"a", "c"}},
+        {"Duplicates", Duplicates[string, string], nil, []string{"b", "b"}},
+        {"Duplicates global last", Duplicates[string, string], &Options{Global: true, KeepLast: true}, []string{"b"}},
+    }
+
+    for _, tc := range testCases {
+        if got := tc.fn(items, key, tc.opts); !reflect.DeepEqual(got, tc.expected) {
+            t.Errorf("%s: got %v, expected %v", tc.name, got, tc.expected)
+        }
+    }
+
+    groups := Count(items, key, &Options{Global: true, KeepLast: true})
+    expected := []Group[string, string]{
+        {Key: "b", Item: "b", Count: 4, First: 1, Last: 5},
+        {Key: "a", Item: "a", Count: 1, First: 3, Last: 3},
+        {Key: "c", Item: "c", Count: 1, First: 6, Last: 6},
+    }
+    if !reflect.DeepEqual(groups, expected) {
+        t.Errorf("Count: got %v, expected %v", groups, expected)
+    }
+}
+
+func feed(items []int) <-chan int {
+    in := make(chan int)
+    go func() {
+        defer close(in)
+        for _, item := range items {
+            in <- item
+        }
+    }()
+    return in
+}
+
+func TestChannels(t *testing.T) {
+    ctx := context.Background()
+    items := []int{1, 1, 2, 3, 3, 3, 1}
+    key := func(i int) int { return i }
+
+    var got []int
+    for item := range DedupChan(ctx, feed(items), key, nil) {
+        got = append(got, item)
+    }
+    if !reflect.DeepEqual(got, []int{1, 2, 3, 1}) {
+        t.Errorf("DedupChan: got %v", got)
+    }
+
+    got = nil
+    for item := range UniqueChan(ctx, feed(items), key, &Options{Global: true}) {
+        got = append(got, item)
+    }
+    if !reflect.DeepEqual(got, []int{2}) {
+        t.Errorf("UniqueChan: got %v", got)
+    }
+
+    var counts []int
+    for g := range CountChan(ctx, feed(items), key, &Options{Global: true}) {
+        counts = append(counts, g.Count)
+    }
+    if !reflect.DeepEqual(counts, []int{3, 1, 3}) {
+        t.Errorf("CountChan: got %v", counts)
+    }
+}
+
+func TestChannelsCanceled(t *testing.T) {
+    ctx, cancel := context.WithCancel(context.Background())
+
+    in := make(chan int)
+    out := DuplicatesChan(ctx, in, func(i int) int { return i }, nil)
+    cancel()
+
+    if _, ok := <-out; ok {
+        t.Errorf("DuplicatesChan sent a value after cancel")
+    }
+}
@@ -0,0 +1,125 @@
+// Package generic finds repeated values of any type like uniq(1) finds
+// repeated lines. Values are equal if their keys are equal.
+//
+// The Grouper of this package is also the engine of the dedup package,
+// which groups text lines by their keys.
+package generic
+
+// Options of grouping. A nil *Options means adjacent groups.
+type Options struct {
+    // Global groups equal values over the whole input,
+    // not only the adjacent ones
+    Global bool
+    // KeepLast makes the last value of a group its representative
+    // instead of the first one (Global)
+    KeepLast bool
+}
+
+// Group is a group of values with equal keys
+type Group[T any, K comparable] struct {
+    Key K
+    // Item represents the group: the first value, or the last one with KeepLast
+    Item  T
+    Count int
+    // positions of the first and the last value of the group, from 1
+    First int
+    Last  int
+}
+
+// Grouper collects the groups of the values added one by one. The key
+// of every value is computed once.
+type Grouper[T any, K comparable] struct {
+    key      func(T) K
+    global   bool
+    keepLast bool
+    n        int
+
+    // the open adjacent group
+    curr *Group[T, K]
+    // the global groups in the order of their first values
+    seen  map[K]*Group[T, K]
+    order []*Group[T, K]
+}
+
+// NewGrouper returns a Grouper of the values with the key function
+func NewGrouper[T any, K comparable](key func(T) K, opts *Options) *Grouper[T, K] {
+    g := &Grouper[T, K]{key: key}
+    if opts != nil {
+        g.global, g.keepLast = opts.Global, opts.KeepLast
+    }
+    if g.global {
+        g.seen = make(map[K]*Group[T, K])
+    }
+    return g
+}
+
+// Add adds the next value. For adjacent groups it returns the group that
+// the value closed, if any. Global groups are returned only by Close.
+func (g *Grouper[T, K]) Add(item T) (closed Group[T, K], ok bool) {
+    g.n += 1
+    key := g.key(item)
+
+    if g.global {
+        if prev, seen := g.seen[key]; seen {
+            g.merge(prev, item)
+            return
+        }
+        group := g.newGroup(key, item)
+        g.seen[key] = group
+        g.order = append(g.order, group)
+        return
+    }
+
+    if g.curr != nil && g.curr.Key == key {
+        g.merge(g.curr, item)
+        return
+    }
+
+    if g.curr != nil {
+        closed, ok = *g.curr, true
+    }
+    g.curr = g.newGroup(key, item)
+    return
+}
+
+func (g *Grouper[T, K]) newGroup(key K, item T) *Group[T, K] {
+    return &Group[T, K]{Key: key, Item: item, Count: 1, First: g.n, Last: g.n}
+}
+
+func (g *Grouper[T, K]) merge(group *Group[T, K], item T) {
+    group.Count += 1
+    group.Last = g.n
+    if g.keepLast {
+        group.Item = item
+    }
+}
+
+// Close returns the groups that are still open: the last adjacent group
+// or all global groups in the order of their first values
+func (g *Grouper[T, K]) Close() (groups []Group[T, K]) {
+    if !g.global {
+        if g.curr != nil {
+            groups = append(groups, *g.curr)
+            g.curr = nil
+        }
+        return
+    }
+
+    groups = make([]Group[T, K], len(g.order))
+    for i, group := range g.order {
+        groups[i] = *group
+    }
+    g.seen, g.order = make(map[K]*Group[T, K]), nil
+    return
+}
+
+// Len returns the number of the groups that are open
+func (g *Grouper[T, K]) Len() int {
+    if g.global {
+        return len(g.order)
+    }
+    if g.curr != nil {
+        return 1
+    }
+    return 0
+}
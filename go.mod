@@ -1,9 +1,14 @@
 module uniq
 
-go 1.16
+go 1.18
 
 require (
 	github.com/fatih/color v1.12.0
-	github.com/golang/mock v1.6.0 // indirect
 	github.com/mattn/go-isatty v0.0.12
 )
+
+require (
+	github.com/golang/mock v1.6.0 // indirect
+	github.com/mattn/go-colorable v0.1.8 // indirect
+	golang.org/x/sys v0.0.0-20210510120138-977fb7262007 // indirect
+)
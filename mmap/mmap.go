@@ -0,0 +1,63 @@
+// Package mmap opens regular files mapped into memory, so that their lines
+// are scanned in place instead of being copied from the file.
+package mmap
+
+import (
+    "bytes"
+    "io"
+    "os"
+)
+
+// File is a read-only file mapped into memory. It is read like a file,
+// and Bytes returns its whole content. The content must not be modified,
+// and the file must not be truncated while it is mapped.
+type File struct {
+    *bytes.Reader
+    data []byte
+}
+
+// Open opens the file for reading. A regular file is mapped into memory
+// if the platform supports it, any other file (a pipe, a device or an empty
+// file) is returned as *os.File.
+func Open(path string) (io.ReadCloser, error) {
+    f, err := os.Open(path)
+    if err != nil {
+        return nil, err
+    }
+
+    info, err := f.Stat()
+    if err != nil {
+        f.Close()
+        return nil, err
+    }
+
+    size := info.Size()
+    if !info.Mode().IsRegular() || size == 0 || int64(int(size)) != size {
+        return f, nil
+    }
+
+    data, err := mmap(f, int(size))
+    if err != nil {
+        // e.g. the file system does not support mapping
+        return f, nil
+    }
+    // the mapping does not need the descriptor
+    f.Close()
+
+    return &File{Reader: bytes.NewReader(data), data: data}, nil
+}
+
+// Bytes returns the content of the file
+func (f *File) Bytes() []byte {
+    return f.data
+}
+
+// Close unmaps the file
+func (f *File) Close() error {
+    if f.data == nil {
+        return os.ErrClosed
+    }
+    data := f.data
+    f.data, f.Reader = nil, bytes.NewReader(nil)
+    return munmap(data)
+}
@@ -0,0 +1,17 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+
+package mmap
+
+import (
+    "errors"
+    "os"
+)
+
+// the files are read by the scanner on other platforms
+func mmap(f *os.File, size int) ([]byte, error) {
+    return nil, errors.New("mmap is not supported")
+}
+
+func munmap(data []byte) error {
+    return nil
+}
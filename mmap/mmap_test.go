@@ -0,0 +1,65 @@
+package mmap
+
+import (
+    "io"
+    "os"
+    "path/filepath"
+    "testing"
+)
+
+func TestOpen(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "input.txt")
+    if err := os.WriteFile(path, []byte("aaa\nbbb\n"), 0644); err != nil {
+        t.Fatal(err)
+    }
+
+    r, err := Open(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    f, ok := r.(*File)
+    if !ok {
+        t.Skipf("the file is not mapped: %T", r)
+    }
+    if string(f.Bytes()) != "aaa\nbbb\n" {
+        t.Errorf("got %q", f.Bytes())
+    }
+
+    if _, err := f.Seek(4, io.SeekStart); err != nil {
+        t.Fatal(err)
+    }
+    if rest, err := io.ReadAll(f); err != nil || string(rest) != "bbb\n" {
+        t.Errorf("got %q, %v", rest, err)
+    }
+
+    if err := f.Close(); err != nil {
+        t.Error(err)
+    }
+    if err := f.Close(); err == nil {
+        t.Error("the file is closed twice")
+    }
+}
+
+func TestOpenNotMapped(t *testing.T) {
+    dir := t.TempDir()
+    empty := filepath.Join(dir, "empty.txt")
+    if err := os.WriteFile(empty, nil, 0644); err != nil {
+        t.Fatal(err)
+    }
+
+    for _, path := range []string{empty, dir} {
+        r, err := Open(path)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if _, ok := r.(*os.File); !ok {
+            t.Errorf("%s: got %T", path, r)
+        }
+        r.Close()
+    }
+
+    if _, err := Open(filepath.Join(dir, "missing")); err == nil {
+        t.Error("no error for a missing file")
+    }
+}
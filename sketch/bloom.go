@@ -0,0 +1,170 @@
+package sketch
+
+import (
+    "errors"
+    "math"
+)
+
+const (
+    // capacity growth and error tightening ratio of ScalableBloom
+    bloomGrowth     = 2
+    bloomTightening = 0.9
+)
+
+// Filter is a set that may report false positives
+type Filter interface {
+    // TestAndAdd adds s and reports whether it was (probably) present
+    TestAndAdd(s string) bool
+    // Count returns the number of distinct items added
+    Count() uint
+    // FalsePositiveRate estimates the current false positive probability
+    FalsePositiveRate() float64
+}
+
+// Bloom is a Bloom filter sized for an expected number of items
+type Bloom struct {
+    bits []uint64
+    m    uint64
+    k    uint64
+    n    uint
+    cap  uint
+}
+
+// NewBloom returns a filter which holds n items with
+// the false positive probability p
+func NewBloom(n uint, p float64) (*Bloom, error) {
+    if err := checkRate(p); err != nil {
+        return nil, err
+    }
+    return newBloom(n, p), nil
+}
+
+func checkRate(p float64) error {
+    if !(p > 0 && p < 1) {
+        return errors.New("bloom: false positive rate must be in (0, 1)")
+    }
+    return nil
+}
+
+func newBloom(n uint, p float64) *Bloom {
+    if n == 0 {
+        n = 1
+    }
+    m := math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2))
+    k := math.Round(m / float64(n) * math.Ln2)
+    if k < 1 {
+        k = 1
+    }
+
+    b := &Bloom{m: uint64(m), k: uint64(k), cap: n}
+    if b.m < 64 {
+        b.m = 64
+    }
+    b.bits = make([]uint64, (b.m+63)/64)
+    return b
+}
+
+func (b *Bloom) TestAndAdd(s string) bool {
+    h1 := Sum64(s)
+    h2 := mix64(h1) | 1
+    present := true
+
+    for i := uint64(0); i < b.k; i++ {
+        bit := (h1 + i*h2) % b.m
+        word, mask := bit/64, uint64(1)<<(bit%64)
+        if b.bits[word]&mask == 0 {
+            present = false
+            b.bits[word] |= mask
+        }
+    }
+
+    if !present {
+        b.n += 1
+    }
+    return present
+}
+
+func (b *Bloom) test(s string) bool {
+    h1 := Sum64(s)
+    h2 := mix64(h1) | 1
+
+    for i := uint64(0); i < b.k; i++ {
+        bit := (h1 + i*h2) % b.m
+        if b.bits[bit/64]&(uint64(1)<<(bit%64)) == 0 {
+            return false
+        }
+    }
+    return true
+}
+
+func (b *Bloom) Count() uint {
+    return b.n
+}
+
+// Cap returns the expected number of items
+func (b *Bloom) Cap() uint {
+    return b.cap
+}
+
+func (b *Bloom) FalsePositiveRate() float64 {
+    return math.Pow(1-math.Exp(-float64(b.k)*float64(b.n)/float64(b.m)), float64(b.k))
+}
+
+// ScalableBloom adds a larger filter with a tighter error every time the
+// current one is full, so the false positive probability stays near p
+// however many items are added
+type ScalableBloom struct {
+    filters []*Bloom
+    p       float64
+}
+
+func NewScalableBloom(n uint, p float64) (*ScalableBloom, error) {
+    if err := checkRate(p); err != nil {
+        return nil, err
+    }
+    p *= 1 - bloomTightening
+    return &ScalableBloom{
+        filters: []*Bloom{newBloom(n, p)},
+        p:       p,
+    }, nil
+}
+
+func (sb *ScalableBloom) TestAndAdd(s string) bool {
+    last := len(sb.filters) - 1
+    for _, b := range sb.filters[:last] {
+        if b.test(s) {
+            return true
+        }
+    }
+
+    b := sb.filters[last]
+    if b.TestAndAdd(s) {
+        return true
+    }
+
+    if b.Count() >= b.Cap() {
+        sb.p *= bloomTightening
+        sb.filters = append(sb.filters, newBloom(b.Cap()*bloomGrowth, sb.p))
+    }
+    return false
+}
+
+func (sb *ScalableBloom) Count() (n uint) {
+    for _, b := range sb.filters {
+        n += b.Count()
+    }
+    return
+}
+
+// Filters returns the number of filters in use
+func (sb *ScalableBloom) Filters() int {
+    return len(sb.filters)
+}
+
+func (sb *ScalableBloom) FalsePositiveRate() float64 {
+    p := 1.0
+    for _, b := range sb.filters {
+        p *= 1 - b.FalsePositiveRate()
+    }
+    return 1 - p
+}
@@ -0,0 +1,62 @@
+package sketch
+
+import (
+    "math"
+    "strconv"
+    "testing"
+)
+
+func testFilter(t *testing.T, name string, f Filter, n int, p float64) {
+    for i := 0; i < n; i++ {
+        f.TestAndAdd(strconv.Itoa(i))
+    }
+
+    for i := 0; i < n; i++ {
+        if !f.TestAndAdd(strconv.Itoa(i)) {
+            t.Fatalf("%s: false negative for %d", name, i)
+        }
+    }
+
+    fp := 0
+    for i := n; i < 2*n; i++ {
+        if f.TestAndAdd(strconv.Itoa(i)) {
+            fp += 1
+        }
+    }
+
+    if rate := float64(fp) / float64(n); rate > 2*p {
+        t.Errorf("%s: false positive rate %g; want <= %g", name, rate, p)
+    }
+    if rate := f.FalsePositiveRate(); rate <= 0 || rate > 2*p {
+        t.Errorf("%s: estimated false positive rate %g; want <= %g", name, rate, p)
+    }
+}
+
+func TestBloom(t *testing.T) {
+    b, err := NewBloom(200000, 0.01)
+    if err != nil {
+        t.Fatal(err)
+    }
+    testFilter(t, "Bloom", b, 100000, 0.01)
+
+    for _, p := range []float64{0, 1, 2, -1, math.NaN()} {
+        if _, err := NewBloom(1000, p); err == nil {
+            t.Errorf("no error for the rate %g", p)
+        }
+        if _, err := NewScalableBloom(1000, p); err == nil {
+            t.Errorf("ScalableBloom: no error for the rate %g", p)
+        }
+    }
+}
+
+func TestScalableBloom(t *testing.T) {
+    sb, err := NewScalableBloom(1000, 0.01)
+    if err != nil {
+        t.Fatal(err)
+    }
+    testFilter(t, "ScalableBloom", sb, 100000, 0.01)
+
+    if sb.Filters() < 2 {
+        t.Errorf("ScalableBloom did not grow: %d filters", sb.Filters())
+    }
+}
@@ -0,0 +1,40 @@
+package sketch
+
+const (
+    offset64 = 14695981039346656037
+    prime64  = 1099511628211
+)
+
+// Sum64 returns the FNV-1a hash of s with an additional avalanche step,
+// so that every bit of the result can be used on its own
+func Sum64(s string) uint64 {
+    h := uint64(offset64)
+    for i := 0; i < len(s); i++ {
+        h ^= uint64(s[i])
+        h *= prime64
+    }
+    return mix64(h)
+}
+
+// mix64 is the finalizer of splitmix64
+func mix64(h uint64) uint64 {
+    h ^= h >> 30
+    h *= 0xbf58476d1ce4e5b9
+    h ^= h >> 27
+    h *= 0x94d049bb133111eb
+    h ^= h >> 31
+    return h
+}
+
+// Sum128 returns two 64-bit hashes of s computed by different functions:
+// Sum64 and FNV-1 with another offset basis
+func Sum128(s string) (h [2]uint64) {
+    h2 := uint64(offset64 ^ 0x9e3779b97f4a7c15)
+    for i := 0; i < len(s); i++ {
+        h2 *= prime64
+        h2 ^= uint64(s[i])
+    }
+    h[0] = Sum64(s)
+    h[1] = mix64(h2 ^ uint64(len(s)))
+    return
+}
@@ -0,0 +1,21 @@
+package sketch
+
+import (
+    "strconv"
+    "testing"
+)
+
+func TestSum128(t *testing.T) {
+    seen := make(map[uint64]bool)
+    for i := 0; i < 100000; i++ {
+        s := strconv.Itoa(i)
+        h := Sum128(s)
+        if h[0] != Sum64(s) {
+            t.Fatalf("Sum128(%q)[0] != Sum64(%q)", s, s)
+        }
+        if h[0] == h[1] || seen[h[1]] {
+            t.Fatalf("Sum128(%q)[1] repeats", s)
+        }
+        seen[h[1]] = true
+    }
+}
@@ -0,0 +1,72 @@
+package sketch
+
+import (
+    "errors"
+    "math"
+    "math/bits"
+)
+
+// HyperLogLog estimates the number of distinct strings in fixed memory:
+// 2^precision one-byte registers, standard error 1.04/sqrt(2^precision)
+type HyperLogLog struct {
+    registers []uint8
+    p         uint8
+}
+
+func NewHyperLogLog(precision uint) (*HyperLogLog, error) {
+    if precision < 4 || precision > 18 {
+        return nil, errors.New("hyperloglog: precision must be in [4, 18]")
+    }
+    return &HyperLogLog{
+        registers: make([]uint8, 1<<precision),
+        p:         uint8(precision),
+    }, nil
+}
+
+func (h *HyperLogLog) Add(s string) {
+    x := Sum64(s)
+    idx := x >> (64 - h.p)
+    // rank of the first set bit in the remaining 64-p bits
+    rank := uint8(bits.LeadingZeros64(x<<h.p|1<<(h.p-1))) + 1
+    if rank > h.registers[idx] {
+        h.registers[idx] = rank
+    }
+}
+
+// Estimate returns the estimated number of distinct strings added
+func (h *HyperLogLog) Estimate() uint64 {
+    m := float64(len(h.registers))
+    sum := 0.0
+    zeros := 0
+
+    for _, r := range h.registers {
+        sum += 1 / float64(uint64(1)<<r)
+        if r == 0 {
+            zeros += 1
+        }
+    }
+
+    var alpha float64
+    switch len(h.registers) {
+    case 16:
+        alpha = 0.673
+    case 32:
+        alpha = 0.697
+    case 64:
+        alpha = 0.709
+    default:
+        alpha = 0.7213 / (1 + 1.079/m)
+    }
+
+    e := alpha * m * m / sum
+    if e <= 2.5*m && zeros > 0 {
+        // linear counting for small cardinalities
+        e = m * math.Log(m/float64(zeros))
+    }
+    return uint64(e + 0.5)
+}
+
+// Error returns the relative standard error of the estimate
+func (h *HyperLogLog) Error() float64 {
+    return 1.04 / math.Sqrt(float64(len(h.registers)))
+}
@@ -0,0 +1,34 @@
+package sketch
+
+import (
+    "math"
+    "strconv"
+    "testing"
+)
+
+func TestHyperLogLog(t *testing.T) {
+    for _, n := range []int{0, 10, 1000, 100000, 1000000} {
+        h, err := NewHyperLogLog(14)
+        if err != nil {
+            t.Fatal(err)
+        }
+
+        for i := 0; i < n; i++ {
+            h.Add(strconv.Itoa(i))
+            h.Add(strconv.Itoa(i))
+        }
+
+        got := float64(h.Estimate())
+        if math.Abs(got-float64(n)) > 4*h.Error()*float64(n)+1 {
+            t.Errorf("Estimate() = %v; want %d ± %.2f%%", got, n, 400*h.Error())
+        }
+    }
+}
+
+func TestHyperLogLogPrecision(t *testing.T) {
+    for _, p := range []uint{3, 19} {
+        if _, err := NewHyperLogLog(p); err == nil {
+            t.Errorf("NewHyperLogLog(%d) did not fail", p)
+        }
+    }
+}
@@ -0,0 +1,111 @@
+package sketch
+
+import (
+    "container/heap"
+    "sort"
+)
+
+// Counter is a monitored string of SpaceSaving. The real number of
+// occurrences of Key is between Count-Error and Count.
+type Counter struct {
+    Key   string
+    Item  string
+    Count uint64
+    Error uint64
+    // insertion order, used to order counters with equal counts
+    seq   uint64
+    index int
+}
+
+type counterHeap []*Counter
+
+func (h counterHeap) Len() int { return len(h) }
+func (h counterHeap) Less(i, j int) bool {
+    if h[i].Count != h[j].Count {
+        return h[i].Count < h[j].Count
+    }
+    return h[i].seq > h[j].seq
+}
+func (h counterHeap) Swap(i, j int) {
+    h[i], h[j] = h[j], h[i]
+    h[i].index = i
+    h[j].index = j
+}
+func (h *counterHeap) Push(x interface{}) {
+    c := x.(*Counter)
+    c.index = len(*h)
+    *h = append(*h, c)
+}
+func (h *counterHeap) Pop() interface{} {
+    old := *h
+    n := len(old)
+    x := old[n-1]
+    *h = old[:n-1]
+    return x
+}
+
+// SpaceSaving finds the most frequent strings of a stream with a fixed
+// number of counters (Metwally et al., the Space-Saving algorithm)
+type SpaceSaving struct {
+    counters counterHeap
+    index    map[string]*Counter
+    capacity int
+    seq      uint64
+}
+
+func NewSpaceSaving(capacity int) *SpaceSaving {
+    if capacity < 1 {
+        capacity = 1
+    }
+    return &SpaceSaving{
+        counters: make(counterHeap, 0, capacity),
+        index:    make(map[string]*Counter, capacity),
+        capacity: capacity,
+    }
+}
+
+// Add counts an occurrence of key; item is kept as its representative
+func (s *SpaceSaving) Add(key, item string) {
+    if c, ok := s.index[key]; ok {
+        c.Count += 1
+        heap.Fix(&s.counters, c.index)
+        return
+    }
+
+    s.seq += 1
+
+    if len(s.counters) < s.capacity {
+        c := &Counter{Key: key, Item: item, Count: 1, seq: s.seq}
+        s.index[key] = c
+        heap.Push(&s.counters, c)
+        return
+    }
+
+    // the least frequent string gives its counter to the new one
+    c := s.counters[0]
+    delete(s.index, c.Key)
+    c.Key, c.Item, c.Error, c.seq = key, item, c.Count, s.seq
+    c.Count += 1
+    s.index[key] = c
+    heap.Fix(&s.counters, 0)
+}
+
+// Top returns at most n counters ordered by count
+func (s *SpaceSaving) Top(n int) []Counter {
+    top := make([]Counter, 0, len(s.counters))
+    for _, c := range s.counters {
+        top = append(top, *c)
+    }
+
+    sort.Slice(top, func(i, j int) bool {
+        if top[i].Count != top[j].Count {
+            return top[i].Count > top[j].Count
+        }
+        return top[i].seq < top[j].seq
+    })
+
+    if n < len(top) {
+        top = top[:n]
+    }
+    return top
+}
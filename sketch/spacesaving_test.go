@@ -0,0 +1,42 @@
+package sketch
+
+import (
+    "strconv"
+    "testing"
+)
+
+func TestSpaceSaving(t *testing.T) {
+    s := NewSpaceSaving(50)
+
+    // 5000 strings occur once and between them
+    // "0" occurs 3000 times, "1" 2000 times and "2" 1000 times
+    expected := map[string]uint64{"0": 3000, "1": 2000, "2": 1000}
+
+    for i := 0; i < 1000; i++ {
+        for j := 0; j < 5; j++ {
+            s.Add(strconv.Itoa(10+5*i+j), "")
+        }
+        s.Add("0", "")
+        s.Add("1", "")
+        s.Add("0", "")
+        s.Add("2", "")
+        s.Add("1", "")
+        s.Add("0", "")
+    }
+
+    top := s.Top(3)
+
+    if len(top) != 3 {
+        t.Fatalf("Top(3) returned %d counters", len(top))
+    }
+
+    for i, key := range []string{"0", "1", "2"} {
+        c := top[i]
+        if c.Key != key {
+            t.Errorf("Top(3)[%d].Key = %q; want %q", i, c.Key, key)
+        }
+        if n := expected[key]; c.Count < n || c.Count-c.Error > n {
+            t.Errorf("count of %q is %d ±%d; want %d", key, c.Count, c.Error, n)
+        }
+    }
+}
@@ -1,6 +1,8 @@
 package utils
 
 import (
+    "bytes"
+    "fmt"
     "io"
     "math/rand"
     "os"
@@ -30,7 +32,7 @@ func ExampleUnique() {
     // ccc
 }
 
-func ExampleUniqueIgnoreCase() {
+func ExampleUnique_ignoreCase() {
     var reader = strings.NewReader(testFile)
     var writer = os.Stdout
 
@@ -42,7 +44,7 @@ func ExampleUniqueIgnoreCase() {
     // ccc
 }
 
-func ExampleUniqueWithBuffer() {
+func ExampleUnique_withBuffer() {
     var reader = strings.NewReader(testFile)
     var writer = os.Stdout
 
@@ -67,7 +69,7 @@ func ExampleDuplicates() {
     // bbb
 }
 
-func ExampleDuplicatesIgnoreCase() {
+func ExampleDuplicates_ignoreCase() {
     var reader = strings.NewReader(testFile)
     var writer = os.Stdout
 
@@ -80,7 +82,7 @@ func ExampleDuplicatesIgnoreCase() {
     // bbb
 }
 
-func ExampleDuplicatesWithBuffer() {
+func ExampleDuplicates_withBuffer() {
     var reader = strings.NewReader(testFile)
     var writer = os.Stdout
 
@@ -106,7 +108,7 @@ func ExampleDeduplicate() {
     // ccc
 }
 
-func ExampleDeduplicateIgnoreCase() {
+func ExampleDeduplicate_ignoreCase() {
     var reader = strings.NewReader(testFile)
     var writer = os.Stdout
 
@@ -120,7 +122,7 @@ func ExampleDeduplicateIgnoreCase() {
     // ccc
 }
 
-func ExampleDeduplicateWithBuffer() {
+func ExampleDeduplicate_withBuffer() {
     var reader = strings.NewReader(testFile)
     var writer = os.Stdout
 
@@ -149,7 +151,7 @@ func ExampleCounterLines() {
 
 }
 
-func ExampleCounterLinesIgnoreCase() {
+func ExampleCounterLines_ignoreCase() {
     var reader = strings.NewReader(testFile)
     var writer = os.Stdout
 
@@ -164,7 +166,7 @@ func ExampleCounterLinesIgnoreCase() {
 
 }
 
-func ExampleCounterLinesWithBuffer() {
+func ExampleCounterLines_withBuffer() {
     var reader = strings.NewReader(testFile)
     var writer = os.Stdout
 
@@ -192,7 +194,7 @@ func ExampleCounterLinesByPrefix() {
     // 1 aa
 }
 
-func ExampleCounterLinesByPrefixIgnoreCase() {
+func ExampleCounterLinesByPrefix_ignoreCase() {
     var reader = strings.NewReader(testFile)
     var writer = os.Stdout
 
@@ -205,7 +207,7 @@ func ExampleCounterLinesByPrefixIgnoreCase() {
     // 2 aa
 }
 
-func ExampleCounterLinesByPrefixWithBuffer() {
+func ExampleCounterLinesByPrefix_withBuffer() {
     var reader = strings.NewReader(testFile)
     var writer = os.Stdout
 
@@ -218,6 +220,324 @@ func ExampleCounterLinesByPrefixWithBuffer() {
     // 1 aa
 }
 
+var testFileUnsorted = (`bbb 1
+AAA 2
+ccc 3
+aaa 4
+bbb 5
+bbb 6`)
+
+func ExampleDeduplicate_global() {
+    var reader = strings.NewReader(testFileUnsorted)
+    var writer = os.Stdout
+
+    cmd := cli.New()
+    cmd.Global = true
+    cmd.Cutter = func(s string) string { return s[:3] }
+
+    Deduplicate(reader, writer, cmd)
+    // Output:
+    // bbb 1
+    // AAA 2
+    // ccc 3
+    // aaa 4
+}
+
+func ExampleDeduplicate_globalKeepLast() {
+    var reader = strings.NewReader(testFileUnsorted)
+    var writer = os.Stdout
+
+    cmd := cli.New()
+    cmd.Global = true
+    cmd.KeepLast = true
+    cmd.Mapper = strings.ToLower
+    cmd.Cutter = func(s string) string { return s[:3] }
+
+    Deduplicate(reader, writer, cmd)
+    // Output:
+    // bbb 6
+    // aaa 4
+    // ccc 3
+}
+
+func ExampleUnique_global() {
+    var reader = strings.NewReader(testFileUnsorted)
+    var writer = os.Stdout
+
+    cmd := cli.New()
+    cmd.Global = true
+    cmd.Cutter = func(s string) string { return s[:3] }
+
+    Unique(reader, writer, cmd)
+    // Output:
+    // AAA 2
+    // ccc 3
+    // aaa 4
+}
+
+func ExampleDuplicates_global() {
+    var reader = strings.NewReader(testFileUnsorted)
+    var writer = os.Stdout
+
+    cmd := cli.New()
+    cmd.Global = true
+    cmd.Mapper = strings.ToLower
+    cmd.Cutter = func(s string) string { return s[:3] }
+
+    Duplicates(reader, writer, cmd)
+    // Output:
+    // bbb 1
+    // aaa 2
+}
+
+func ExampleCounterLines_global() {
+    var reader = strings.NewReader(testFileUnsorted)
+    var writer = os.Stdout
+
+    cmd := cli.New()
+    cmd.Global = true
+    cmd.Cutter = func(s string) string { return s[:3] }
+
+    CounterLines(reader, writer, cmd)
+    // Output:
+    // 3 bbb 1
+    // 1 AAA 2
+    // 1 ccc 3
+    // 1 aaa 4
+}
+
+func ExampleDuplicates_bloom() {
+    var reader = strings.NewReader(testFileUnsorted)
+    var writer = os.Stdout
+
+    cmd := cli.New()
+    cmd.Bloom = true
+    cmd.BloomItems = 100
+    cmd.Cutter = func(s string) string { return s[:3] }
+
+    Duplicates(reader, writer, cmd)
+    // Output:
+    // bbb 5
+}
+
+func ExampleCardinality() {
+    var reader = strings.NewReader(testFileUnsorted)
+    var writer = os.Stdout
+
+    cmd := cli.New()
+    cmd.ExactLimit = 100
+    cmd.Mapper = strings.ToLower
+    cmd.Cutter = func(s string) string { return s[:3] }
+
+    Cardinality(reader, writer, cmd)
+    // Output:
+    // 3 distinct (±0.81%)
+    // 3 distinct (exact)
+}
+
+func ExampleTopLines() {
+    var reader = strings.NewReader(testFileUnsorted)
+    var writer = os.Stdout
+
+    cmd := cli.New()
+    cmd.Top = 2
+    cmd.Mapper = strings.ToLower
+    cmd.Cutter = func(s string) string { return s[:3] }
+
+    TopLines(reader, writer, cmd)
+    // Output:
+    // 3 ±0 bbb 1
+    // 2 ±0 aaa 2
+}
+
+var testFileInterleaved = (`aaa
+bbb
+aaa
+ccc
+bbb
+aaa
+aaa
+ddd`)
+
+func ExampleDeduplicate_window() {
+    var reader = strings.NewReader(testFileInterleaved)
+    var writer = os.Stdout
+
+    cmd := cli.New()
+    cmd.Window = 2
+
+    Deduplicate(reader, writer, cmd)
+    // Output:
+    // aaa
+    // bbb
+    // ccc
+    // bbb
+    // aaa
+    // ddd
+}
+
+func ExampleCounterLines_window() {
+    var reader = strings.NewReader(testFileInterleaved)
+    var writer = os.Stdout
+
+    cmd := cli.New()
+    cmd.Window = 2
+
+    CounterLines(reader, writer, cmd)
+    // Output:
+    // 1 bbb
+    // 2 aaa
+    // 1 ccc
+    // 1 bbb
+    // 2 aaa
+    // 1 ddd
+}
+
+func ExampleUnique_cache() {
+    var reader = strings.NewReader(testFileInterleaved)
+    var writer = os.Stdout
+
+    cmd := cli.New()
+    cmd.CacheSize = 2
+
+    Unique(reader, writer, cmd)
+    // Output:
+    // bbb
+    // ccc
+    // bbb
+    // ddd
+}
+
+func TestGlobalMemoryLimit(t *testing.T) {
+
+    var data = make([]string, 0, 20000)
+    for i := 0; i < cap(data); i++ {
+        data = append(data, fmt.Sprintf("%s %d", randSeq(2), i))
+    }
+    var input = strings.Join(data, "\n")
+
+    modes := map[string]func(io.Reader, io.Writer, *cli.Cmd){
+        "Deduplicate":  Deduplicate,
+        "Unique":       Unique,
+        "Duplicates":   Duplicates,
+        "CounterLines": CounterLines,
+    }
+
+    for name, fn := range modes {
+        for _, keepLast := range []bool{false, true} {
+            var expected, got bytes.Buffer
+
+            cmd := cli.New()
+            cmd.Global = true
+            cmd.KeepLast = keepLast
+            cmd.Mapper = strings.ToLower
+            cmd.Cutter = func(s string) string { return s[:2] }
+
+            fn(strings.NewReader(input), &expected, cmd)
+
+            cmd.MemoryLimit = 16
+            fn(strings.NewReader(input), &got, cmd)
+
+            if got.String() != expected.String() {
+                t.Errorf("%s(KeepLast=%v) with MemoryLimit differs from in-memory result",
+                    name, keepLast)
+            }
+        }
+    }
+}
+
+func ExampleSortLines() {
+    var reader = strings.NewReader(testFileUnsorted)
+    var writer = os.Stdout
+
+    cmd := cli.New()
+    cmd.Cutter = func(s string) string { return s[:3] }
+
+    sorted := SortLines(reader, cmd)
+    defer sorted.Close()
+
+    Deduplicate(sorted, writer, cmd)
+    // Output:
+    // AAA 2
+    // aaa 4
+    // bbb 1
+    // ccc 3
+}
+
+func TestSortLinesMemoryLimit(t *testing.T) {
+
+    var data = make([]string, 0, 20000)
+    for i := 0; i < cap(data); i++ {
+        data = append(data, randSeq(5))
+    }
+
+    cmd := cli.New()
+    cmd.MemoryLimit = 16
+
+    sorted := SortLines(strings.NewReader(strings.Join(data, "\n")), cmd)
+    defer sorted.Close()
+
+    got, err := io.ReadAll(sorted)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    sort.Strings(data)
+    if string(got) != strings.Join(data, "\n")+"\n" {
+        t.Errorf("SortLines with MemoryLimit returned unsorted lines")
+    }
+}
+
+func TestGlobalFingerprint(t *testing.T) {
+
+    var data = make([]string, 0, 20000)
+    for i := 0; i < cap(data); i++ {
+        data = append(data, fmt.Sprintf("%s %d", randSeq(2), i))
+    }
+    var input = strings.Join(data, "\n")
+
+    modes := map[string]func(io.Reader, io.Writer, *cli.Cmd){
+        "Deduplicate":  Deduplicate,
+        "Unique":       Unique,
+        "Duplicates":   Duplicates,
+        "CounterLines": CounterLines,
+    }
+
+    for name, fn := range modes {
+        for _, keepLast := range []bool{false, true} {
+            var expected bytes.Buffer
+
+            cmd := cli.New()
+            cmd.Global = true
+            cmd.KeepLast = keepLast
+            cmd.Mapper = strings.ToLower
+            cmd.Cutter = func(s string) string { return s[:2] }
+
+            fn(strings.NewReader(input), &expected, cmd)
+
+            for _, bits := range []uint{64, 128} {
+                var seekable, pipe bytes.Buffer
+
+                cmd.Fingerprint = bits
+                cmd.Verify = true
+                fn(strings.NewReader(input), &seekable, cmd)
+
+                cmd.Verify = false
+                fn(struct{ io.Reader }{strings.NewReader(input)}, &pipe, cmd)
+
+                if seekable.String() != expected.String() {
+                    t.Errorf("%s(KeepLast=%v) with %d-bit fingerprints of seekable input differs from in-memory result",
+                        name, keepLast, bits)
+                }
+                if pipe.String() != expected.String() {
+                    t.Errorf("%s(KeepLast=%v) with %d-bit fingerprints differs from in-memory result",
+                        name, keepLast, bits)
+                }
+            }
+        }
+    }
+}
+
 func TestSubstring(t *testing.T) {
 
     testCases := []struct {
@@ -336,6 +656,8 @@ func BenchmarkUnique10000(b *testing.B) {
     b.ResetTimer()
 
     for i := 0; i < b.N; i++ {
+        // the reader is read to the end by every run
+        reader.Seek(0, io.SeekStart)
         Unique(reader, writer, cmd)
     }
 }
@@ -350,6 +672,8 @@ func BenchmarkDuplicates10000(b *testing.B) {
     b.ResetTimer()
 
     for i := 0; i < b.N; i++ {
+        // the reader is read to the end by every run
+        reader.Seek(0, io.SeekStart)
         Duplicates(reader, writer, cmd)
     }
 }
@@ -364,6 +688,8 @@ func BenchmarkDeduplicate10000(b *testing.B) {
     b.ResetTimer()
 
     for i := 0; i < b.N; i++ {
+        // the reader is read to the end by every run
+        reader.Seek(0, io.SeekStart)
         Deduplicate(reader, writer, cmd)
     }
 }
@@ -378,6 +704,8 @@ func BenchmarkUnique100000(b *testing.B) {
     b.ResetTimer()
 
     for i := 0; i < b.N; i++ {
+        // the reader is read to the end by every run
+        reader.Seek(0, io.SeekStart)
         Unique(reader, writer, cmd)
     }
 }
@@ -393,6 +721,8 @@ func BenchmarkUniqueWithBuffer100000(b *testing.B) {
     b.ResetTimer()
 
     for i := 0; i < b.N; i++ {
+        // the reader is read to the end by every run
+        reader.Seek(0, io.SeekStart)
         Unique(reader, writer, cmd)
     }
 }
@@ -407,6 +737,8 @@ func BenchmarkDuplicates100000(b *testing.B) {
     b.ResetTimer()
 
     for i := 0; i < b.N; i++ {
+        // the reader is read to the end by every run
+        reader.Seek(0, io.SeekStart)
         Duplicates(reader, writer, cmd)
     }
 }
@@ -421,10 +753,74 @@ func BenchmarkDeduplicate100000(b *testing.B) {
     b.ResetTimer()
 
     for i := 0; i < b.N; i++ {
+        // the reader is read to the end by every run
+        reader.Seek(0, io.SeekStart)
+        Deduplicate(reader, writer, cmd)
+    }
+}
+
+func benchmarkKey(b *testing.B, cmd *cli.Cmd) {
+
+    var reader = strings.NewReader(testFile100K)
+    var writer = io.Discard
+
+    b.ResetTimer()
+
+    for i := 0; i < b.N; i++ {
+        reader.Seek(0, io.SeekStart)
         Deduplicate(reader, writer, cmd)
     }
 }
 
+// the lines are strings when Mapper or Cutter are set
+func BenchmarkDeduplicateStrings100000(b *testing.B) {
+
+    cmd := cli.New()
+    cmd.Mapper = func(s string) string { return s }
+
+    benchmarkKey(b, cmd)
+}
+
+func BenchmarkDeduplicateIgnoreCase100000(b *testing.B) {
+
+    cmd := cli.New()
+    cmd.IgnoreCase = true
+    cmd.SkipChars = 2
+
+    benchmarkKey(b, cmd)
+}
+
+func BenchmarkDeduplicateIgnoreCaseStrings100000(b *testing.B) {
+
+    cmd := cli.New()
+    cmd.Mapper = strings.ToLower
+    cmd.Cutter = func(line string) string {
+        idx := Substring(line, 0, 2, 0)
+        return line[idx[0]:idx[1]]
+    }
+
+    benchmarkKey(b, cmd)
+}
+
+func BenchmarkDeduplicateFields100000(b *testing.B) {
+
+    cmd := cli.New()
+    cmd.NumFields = 1
+
+    benchmarkKey(b, cmd)
+}
+
+func BenchmarkDeduplicateFieldsStrings100000(b *testing.B) {
+
+    cmd := cli.New()
+    cmd.Cutter = func(line string) string {
+        idx := Substring(line, 1, 0, 0)
+        return line[idx[0]:idx[1]]
+    }
+
+    benchmarkKey(b, cmd)
+}
+
 // go test -v ./utils
 /*
 === RUN   TestSubstring
@@ -501,6 +897,20 @@ BenchmarkDeduplicate100000-4              253906              4025 ns/op
 PASS
 ok      uniq/utils      9.822s
 */
+
+// the reader is rewound in every run and the lines are bytes unless
+// Mapper or Cutter are set (the *Strings benchmarks)
+//go test -bench=Deduplicate -benchmem ./utils
+/*
+BenchmarkDeduplicate10000                   	    2958	    426995 ns/op	    4672 B/op	       7 allocs/op
+BenchmarkDeduplicate100000                  	     507	   2447577 ns/op	    4672 B/op	       7 allocs/op
+BenchmarkDeduplicateStrings100000           	      73	  20340680 ns/op	14404992 B/op	  300009 allocs/op
+BenchmarkDeduplicateIgnoreCase100000        	     195	   6541993 ns/op	    4752 B/op	      11 allocs/op
+BenchmarkDeduplicateIgnoreCaseStrings100000 	      36	  29021500 ns/op	16003360 B/op	  399907 allocs/op
+BenchmarkDeduplicateFields100000            	     283	   4144685 ns/op	    4728 B/op	       9 allocs/op
+BenchmarkDeduplicateFieldsStrings100000     	     192	   6235158 ns/op	 1605120 B/op	  100011 allocs/op
+PASS
+*/
  
 // запустить только одну bench функцию - по точному имени
 // go test -bench=^BenchmarkUnique10000$ -benchmem